@@ -2,11 +2,13 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"os"
 	"os/signal"
 	"syscall"
 
 	"yourapp/internal/bootstrap"
+	"yourapp/internal/command"
 	"yourapp/internal/global"
 	"yourapp/pkg/cli"
 	"yourapp/pkg/config"
@@ -16,8 +18,17 @@ import (
 )
 
 func main() {
+	// Run a subcommand instead of the server if one was given
+	if handled, err := command.Run(context.Background(), os.Args[1:]); handled {
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	// Parse command line flags
-	_ = cli.ParseFlags()
+	flags := cli.ParseFlags()
 
 	// Initialize global configuration
 	global.Init()
@@ -29,11 +40,22 @@ func main() {
 	}
 	global.SetConfig(cfg)
 
+	// Print where each setting came from if requested
+	if flags.ConfigSources {
+		if err := config.WriteOrigins(os.Stdout, cfg); err != nil {
+			logger.Fatalf("Failed to print configuration: %v", err)
+		}
+		return
+	}
+
 	// Initialize logger
 	if err := logger.Init(); err != nil {
 		logger.Fatalf("Failed to initialize logger: %v", err)
 	}
 	defer logger.Sync()
+	for _, warning := range config.Deprecations() {
+		logger.Warn(warning)
+	}
 
 	// Log startup information
 	logger.Info("Starting application",
@@ -42,6 +64,7 @@ func main() {
 		zap.String("host", cfg.Server.Host),
 		zap.Int("port", cfg.Server.Port),
 	)
+	logger.Debug("Effective configuration", zap.Stringer("config", cfg))
 
 	// Bootstrap the application
 	ctx, cancel := context.WithCancel(context.Background())
@@ -8,9 +8,13 @@ import (
 	"yourapp/pkg/cache/redisx"
 	"yourapp/pkg/logger"
 	"yourapp/pkg/messaging/kafka"
+	"yourapp/pkg/messaging/outbox"
+	"yourapp/pkg/messaging/schemaregistry"
 	"yourapp/pkg/storage/elasticsearch"
 	"yourapp/pkg/storage/mysql"
 	"yourapp/pkg/storage/postgres"
+
+	"gorm.io/gorm"
 )
 
 // Start initializes and starts all application services
@@ -37,6 +41,19 @@ func Start(ctx context.Context) error {
 		return fmt.Errorf("failed to initialize Kafka: %w", err)
 	}
 
+	// Initialize transactional outbox
+	if err := initOutbox(ctx); err != nil {
+		return fmt.Errorf("failed to initialize outbox: %w", err)
+	}
+
+	// Serve metrics and health endpoints
+	if err := initHTTPServer(); err != nil {
+		return fmt.Errorf("failed to start HTTP server: %w", err)
+	}
+
+	// Apply config file changes while running
+	watchConfig()
+
 	logger.Info("Application bootstrap completed successfully")
 	return nil
 }
@@ -87,6 +104,13 @@ func initElasticsearch(ctx context.Context) error {
 			return fmt.Errorf("failed to initialize Elasticsearch: %w", err)
 		}
 		logger.Info("Elasticsearch connection initialized")
+
+		if dir := cfg.Elasticsearch.TemplatesDir; dir != "" {
+			if err := elasticsearch.ApplyTemplatesDir(ctx, dir); err != nil {
+				return fmt.Errorf("failed to apply Elasticsearch templates: %w", err)
+			}
+			logger.Infof("Elasticsearch templates from %s applied", dir)
+		}
 	}
 
 	return nil
@@ -101,6 +125,41 @@ func initKafka(ctx context.Context) error {
 			return fmt.Errorf("failed to initialize Kafka: %w", err)
 		}
 		logger.Info("Kafka connection initialized")
+
+		if err := kafka.EnsureTopics(ctx, cfg.Kafka.Topics); err != nil {
+			return fmt.Errorf("failed to ensure Kafka topics: %w", err)
+		}
+
+		if cfg.Kafka.SchemaRegistry.URL != "" {
+			if err := schemaregistry.Init(ctx, cfg.Kafka.SchemaRegistry); err != nil {
+				return fmt.Errorf("failed to initialize schema registry client: %w", err)
+			}
+			logger.Info("Schema registry client initialized")
+		}
+	}
+
+	return nil
+}
+
+// initOutbox starts the outbox relay on the configured database
+func initOutbox(ctx context.Context) error {
+	cfg := global.GetConfig()
+
+	if cfg.Outbox.Enabled {
+		var db *gorm.DB
+		switch cfg.Outbox.Database {
+		case "mysql":
+			db = mysql.GetDB()
+		case "postgres":
+			db = postgres.GetDB()
+		default:
+			return fmt.Errorf("unsupported outbox database %q", cfg.Outbox.Database)
+		}
+
+		if err := outbox.Init(ctx, cfg.Outbox, db); err != nil {
+			return err
+		}
+		logger.Info("Outbox relay started")
 	}
 
 	return nil
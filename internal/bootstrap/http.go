@@ -0,0 +1,147 @@
+package bootstrap
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"yourapp/internal/global"
+	"yourapp/pkg/cache/redisx"
+	"yourapp/pkg/logger"
+	"yourapp/pkg/messaging/kafka"
+	"yourapp/pkg/messaging/schemaregistry"
+	"yourapp/pkg/metrics"
+	"yourapp/pkg/storage/elasticsearch"
+	"yourapp/pkg/storage/mysql"
+	"yourapp/pkg/storage/postgres"
+)
+
+// healthTimeout bounds all component checks of one health request
+const healthTimeout = 5 * time.Second
+
+var httpServer *http.Server
+
+// healthCheck checks one component
+type healthCheck struct {
+	name  string
+	check func(ctx context.Context) error
+}
+
+// componentStatus is the health of one component in a health response
+type componentStatus struct {
+	Status  string `json:"status"`
+	Error   string `json:"error,omitempty"`
+	Details any    `json:"details,omitempty"`
+}
+
+// healthResponse is the body of the /health and /ready endpoints
+type healthResponse struct {
+	Status     string                     `json:"status"`
+	Components map[string]componentStatus `json:"components"`
+}
+
+// initHTTPServer starts the HTTP server exposing /metrics, /health and /ready
+func initHTTPServer() error {
+	cfg := global.GetConfig().Server
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics.Handler())
+	mux.HandleFunc("/health", handleHealth(false))
+	mux.HandleFunc("/ready", handleHealth(true))
+
+	addr := net.JoinHostPort(cfg.Host, strconv.Itoa(cfg.Port))
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", addr, err)
+	}
+
+	httpServer = &http.Server{
+		Handler:      mux,
+		ReadTimeout:  cfg.ReadTimeout,
+		WriteTimeout: cfg.WriteTimeout,
+	}
+	go func() {
+		if err := httpServer.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Errorf("HTTP server stopped: %v", err)
+		}
+	}()
+
+	return nil
+}
+
+// closeHTTPServer stops the HTTP server, waiting for active requests until ctx is done
+func closeHTTPServer(ctx context.Context) error {
+	if httpServer == nil {
+		return nil
+	}
+	return httpServer.Shutdown(ctx)
+}
+
+// healthChecks returns the checks of the enabled components
+func healthChecks() []healthCheck {
+	cfg := global.GetConfig()
+
+	var checks []healthCheck
+	if cfg.Database.MySQL.Enabled {
+		checks = append(checks, healthCheck{"mysql", mysql.Health})
+	}
+	if cfg.Database.PostgreSQL.Enabled {
+		checks = append(checks, healthCheck{"postgres", postgres.Health})
+	}
+	if cfg.Cache.Redis.Enabled {
+		checks = append(checks, healthCheck{"redis", redisx.Health})
+	}
+	if cfg.Elasticsearch.Enabled {
+		checks = append(checks, healthCheck{"elasticsearch", elasticsearch.Health})
+	}
+	if cfg.Kafka.Enabled {
+		checks = append(checks, healthCheck{"kafka", kafka.Health})
+		if cfg.Kafka.SchemaRegistry.URL != "" {
+			checks = append(checks, healthCheck{"schema_registry", schemaregistry.Health})
+		}
+	}
+	return checks
+}
+
+// handleHealth reports the health of every enabled component. For readiness the Kafka
+// consumer statistics are included and lag above the threshold marks the service degraded.
+func handleHealth(readiness bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), healthTimeout)
+		defer cancel()
+
+		resp := healthResponse{Status: "up", Components: make(map[string]componentStatus)}
+		for _, c := range healthChecks() {
+			status := componentStatus{Status: "up"}
+			if err := c.check(ctx); err != nil {
+				status = componentStatus{Status: "down", Error: err.Error()}
+				resp.Status = "down"
+			}
+
+			if readiness && c.name == "kafka" {
+				stats := kafka.Stats()
+				status.Details = stats
+				if stats.Degraded && status.Status == "up" {
+					status.Status = "degraded"
+					if resp.Status == "up" {
+						resp.Status = "degraded"
+					}
+				}
+			}
+			resp.Components[c.name] = status
+		}
+
+		code := http.StatusOK
+		if resp.Status != "up" {
+			code = http.StatusServiceUnavailable
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(code)
+		_ = json.NewEncoder(w).Encode(resp)
+	}
+}
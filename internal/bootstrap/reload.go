@@ -0,0 +1,49 @@
+package bootstrap
+
+import (
+	"context"
+
+	"yourapp/internal/global"
+	"yourapp/pkg/cache/redisx"
+	"yourapp/pkg/config"
+	"yourapp/pkg/logger"
+)
+
+// watchConfig applies changes to the config file while the application runs. Only settings
+// tagged reload:"live" change; the others are reported and keep their value until a restart.
+func watchConfig() {
+	global.Subscribe("logging.level", func(_, cfg *config.Config) {
+		if err := logger.SetLevel(cfg.Logging.Level); err != nil {
+			logger.Errorf("Failed to change log level: %v", err)
+			return
+		}
+		logger.Infof("Log level changed to %s", cfg.Logging.Level)
+	})
+
+	global.Subscribe("cache.redis", func(_, cfg *config.Config) {
+		if !cfg.Cache.Redis.Enabled {
+			return
+		}
+		if err := redisx.Reconfigure(context.Background(), cfg.Cache.Redis); err != nil {
+			logger.Errorf("Failed to apply Redis pool settings: %v", err)
+			return
+		}
+		logger.Infof("Redis pool resized to %d connections, %d idle", cfg.Cache.Redis.PoolSize, cfg.Cache.Redis.MinIdleConns)
+	})
+
+	watching := config.Watch(func(next *config.Config, err error) {
+		if err != nil {
+			logger.Errorf("Ignoring config file change: %v", err)
+			return
+		}
+
+		cfg, ignored := config.ApplyLive(global.GetConfig(), next)
+		for _, path := range ignored {
+			logger.Warnf("Config %s changed but only takes effect after a restart; keeping the current value", path)
+		}
+		global.SetConfig(cfg)
+	})
+	if watching {
+		logger.Info("Watching config file for changes")
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"yourapp/pkg/cache/redisx"
 	"yourapp/pkg/logger"
 	"yourapp/pkg/messaging/kafka"
+	"yourapp/pkg/messaging/outbox"
 	"yourapp/pkg/storage/elasticsearch"
 	"yourapp/pkg/storage/mysql"
 	"yourapp/pkg/storage/postgres"
@@ -17,6 +18,16 @@ import (
 func Shutdown(ctx context.Context) error {
 	logger.Info("Starting graceful shutdown...")
 
+	// Stop serving metrics and health checks
+	if err := closeHTTPServer(ctx); err != nil {
+		logger.Error("Error stopping HTTP server", zap.Error(err))
+	}
+
+	// Stop the outbox relay while its database and Kafka are still available
+	if err := outbox.Close(); err != nil {
+		logger.Error("Error stopping outbox relay", zap.Error(err))
+	}
+
 	// Close database connections
 	if err := mysql.Close(); err != nil {
 		logger.Error("Error closing MySQL connection", zap.Error(err))
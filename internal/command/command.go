@@ -0,0 +1,93 @@
+package command
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"yourapp/internal/global"
+	"yourapp/pkg/config"
+	"yourapp/pkg/logger"
+
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+// Command represents a subcommand of the server binary
+type Command struct {
+	Name  string
+	Usage string
+	Run   func(ctx context.Context, args []string) error
+}
+
+var commands = map[string]*Command{}
+
+// register adds a subcommand to the registry
+func register(cmd *Command) {
+	commands[cmd.Name] = cmd
+}
+
+// Run executes the subcommand named by args[0].
+// It returns false when args does not name a subcommand and the server should start normally.
+func Run(ctx context.Context, args []string) (bool, error) {
+	if len(args) == 0 {
+		return false, nil
+	}
+
+	cmd, ok := commands[args[0]]
+	if !ok {
+		return false, nil
+	}
+
+	if err := cmd.Run(ctx, args[1:]); err != nil && !errors.Is(err, pflag.ErrHelp) {
+		return true, err
+	}
+	return true, nil
+}
+
+// newFlagSet creates a flag set for a subcommand with the shared --config flag, unless
+// configFile is nil
+func newFlagSet(name string, configFile *string) *pflag.FlagSet {
+	fs := pflag.NewFlagSet(name, pflag.ContinueOnError)
+	if configFile != nil {
+		fs.StringVarP(configFile, "config", "c", "", "Path to configuration file")
+	}
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s %s [OPTIONS]\n\n", os.Args[0], name)
+		fmt.Fprintf(os.Stderr, "%s\n\nOptions:\n", commands[name].Usage)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// readConfig loads the configuration from configFile, or the default locations when empty
+func readConfig(configFile string) (*config.Config, error) {
+	if configFile != "" {
+		viper.SetConfigFile(configFile)
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load configuration: %w", err)
+	}
+	return cfg, nil
+}
+
+// loadConfig loads the configuration and initializes the logger for a subcommand
+func loadConfig(configFile string) (*config.Config, error) {
+	cfg, err := readConfig(configFile)
+	if err != nil {
+		return nil, err
+	}
+	global.SetConfig(cfg)
+
+	if err := logger.Init(); err != nil {
+		return nil, fmt.Errorf("failed to initialize logger: %w", err)
+	}
+	for _, warning := range config.Deprecations() {
+		logger.Warn(warning)
+	}
+
+	return cfg, nil
+}
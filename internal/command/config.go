@@ -0,0 +1,155 @@
+package command
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"yourapp/pkg/config"
+)
+
+// configCommands are run as "config <name>"; they are registered under their full name so
+// newFlagSet finds their usage
+var configCommands = []*Command{
+	{
+		Name:  "config print",
+		Usage: "Print the effective configuration with secrets redacted, or where each value came from",
+		Run:   runConfigPrint,
+	},
+	{
+		Name:  "config validate",
+		Usage: "Validate a config file, with the layers merged over it, as the server would load it",
+		Run:   runConfigValidate,
+	},
+	{
+		Name:  "config init",
+		Usage: "Generate a config file with every setting, its default value and a description",
+		Run:   runConfigInit,
+	},
+}
+
+func init() {
+	register(&Command{
+		Name:  "config",
+		Usage: "Print, validate or generate configuration",
+		Run:   runConfig,
+	})
+	for _, cmd := range configCommands {
+		register(cmd)
+	}
+}
+
+// runConfig runs the config subcommand named by args[0]
+func runConfig(ctx context.Context, args []string) error {
+	if len(args) > 0 {
+		if cmd, ok := commands["config "+args[0]]; ok {
+			return cmd.Run(ctx, args[1:])
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "Usage: %s config <command> [OPTIONS]\n\nCommands:\n", os.Args[0])
+	for _, cmd := range configCommands {
+		fmt.Fprintf(os.Stderr, "  %-10s %s\n", strings.TrimPrefix(cmd.Name, "config "), cmd.Usage)
+	}
+	if len(args) == 0 {
+		return fmt.Errorf("a config command is required")
+	}
+	return fmt.Errorf("unknown config command %q", args[0])
+}
+
+// runConfigPrint prints the effective configuration
+func runConfigPrint(_ context.Context, args []string) error {
+	var configFile, format string
+	var sources bool
+
+	fs := newFlagSet("config print", &configFile)
+	fs.StringVarP(&format, "format", "f", "yaml", "Output format (yaml, json)")
+	fs.BoolVarP(&sources, "sources", "s", false, "Print each setting with the layer it came from instead")
+	fs.StringP("env", "e", "", "Environment, selecting config.<env>.yaml")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := config.BindFlag("app.env", fs.Lookup("env")); err != nil {
+		return err
+	}
+
+	cfg, err := readConfig(configFile)
+	if err != nil {
+		return err
+	}
+
+	if sources {
+		return config.WriteOrigins(os.Stdout, cfg)
+	}
+	return config.Encode(os.Stdout, cfg, format)
+}
+
+// runConfigValidate loads a config file and reports every problem in it
+func runConfigValidate(_ context.Context, args []string) error {
+	fs := newFlagSet("config validate", nil)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s config validate [FILE]\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "%s. Without FILE the default locations are searched.\n", commands["config validate"].Usage)
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 1 {
+		fs.Usage()
+		return fmt.Errorf("expected at most one config file")
+	}
+
+	if _, err := readConfig(fs.Arg(0)); err != nil {
+		return err
+	}
+	for _, warning := range config.Deprecations() {
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", warning)
+	}
+
+	files := config.Files()
+	if len(files) == 0 {
+		fmt.Println("No config file found; the defaults and environment variables are valid")
+		return nil
+	}
+	fmt.Printf("Configuration is valid: %s\n", strings.Join(files, ", "))
+	return nil
+}
+
+// runConfigInit writes a commented config file made of the defaults
+func runConfigInit(_ context.Context, args []string) error {
+	var output string
+	var force bool
+
+	fs := newFlagSet("config init", nil)
+	fs.StringVarP(&output, "output", "o", "", "File to write instead of standard output")
+	fs.BoolVar(&force, "force", false, "Overwrite the output file if it exists")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if output == "" {
+		return config.WriteTemplate(os.Stdout)
+	}
+
+	flag := os.O_WRONLY | os.O_CREATE | os.O_EXCL
+	if force {
+		flag = os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	}
+	f, err := os.OpenFile(output, flag, 0o644)
+	if err != nil {
+		if os.IsExist(err) {
+			return fmt.Errorf("%s already exists, use --force to overwrite it", output)
+		}
+		return fmt.Errorf("failed to create %s: %w", output, err)
+	}
+	if err := config.WriteTemplate(f); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to write %s: %w", output, err)
+	}
+	fmt.Printf("Wrote %s\n", output)
+	return nil
+}
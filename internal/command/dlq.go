@@ -0,0 +1,60 @@
+package command
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"yourapp/pkg/logger"
+	"yourapp/pkg/messaging/kafka"
+)
+
+func init() {
+	register(&Command{
+		Name:  "dlq-replay",
+		Usage: "Replay messages from a Kafka dead-letter topic to their source topic",
+		Run:   runDLQReplay,
+	})
+}
+
+// runDLQReplay replays a dead-letter topic until it is drained, the limit is reached or the
+// command is interrupted
+func runDLQReplay(ctx context.Context, args []string) error {
+	var configFile, topic string
+	var limit int
+
+	fs := newFlagSet("dlq-replay", &configFile)
+	fs.StringVarP(&topic, "topic", "t", "", "Dead-letter topic to replay (required)")
+	fs.IntVarP(&limit, "limit", "n", 0, "Maximum number of messages to replay (0 = all)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if topic == "" {
+		fs.Usage()
+		return fmt.Errorf("--topic is required")
+	}
+
+	cfg, err := loadConfig(configFile)
+	if err != nil {
+		return err
+	}
+	defer logger.Sync()
+
+	// Stop replaying on Ctrl-C or SIGTERM; messages already replayed have been committed
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := kafka.Init(ctx, cfg.Kafka); err != nil {
+		return fmt.Errorf("failed to initialize Kafka: %w", err)
+	}
+	defer func() { _ = kafka.Close() }()
+
+	replayed, err := kafka.ReplayDLQ(ctx, topic, limit)
+	if ctx.Err() != nil {
+		logger.Warnf("Replay of %s interrupted", topic)
+	}
+	logger.Infof("Replayed %d messages from %s", replayed, topic)
+	return err
+}
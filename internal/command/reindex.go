@@ -0,0 +1,64 @@
+package command
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"yourapp/pkg/logger"
+	"yourapp/pkg/storage/elasticsearch"
+)
+
+func init() {
+	register(&Command{
+		Name:  "reindex",
+		Usage: "Rebuild an Elasticsearch index behind its alias from a mapping file, without downtime",
+		Run:   runReindex,
+	})
+}
+
+// runReindex copies the documents behind an alias into a new versioned index and swaps the alias
+func runReindex(ctx context.Context, args []string) error {
+	var configFile, alias, mappingFile string
+	var keep int
+
+	fs := newFlagSet("reindex", &configFile)
+	fs.StringVarP(&alias, "alias", "a", "", "Alias to reindex (required)")
+	fs.StringVarP(&mappingFile, "mapping", "m", "", "JSON file with the settings and mappings of the new index")
+	fs.IntVarP(&keep, "keep", "k", 1, "Number of previous index versions to keep")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if alias == "" {
+		fs.Usage()
+		return fmt.Errorf("--alias is required")
+	}
+
+	cfg, err := loadConfig(configFile)
+	if err != nil {
+		return err
+	}
+	defer logger.Sync()
+
+	// Stop on Ctrl-C or SIGTERM so the _reindex task is cancelled and the new index deleted
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := elasticsearch.Init(ctx, cfg.Elasticsearch); err != nil {
+		return fmt.Errorf("failed to initialize Elasticsearch: %w", err)
+	}
+	defer func() { _ = elasticsearch.Close() }()
+
+	index, err := elasticsearch.Reindex(ctx, elasticsearch.ReindexOptions{
+		Alias:       alias,
+		MappingFile: mappingFile,
+		Keep:        keep,
+	})
+	if err != nil {
+		return err
+	}
+	logger.Infof("Reindexed %s into %s", alias, index)
+	return nil
+}
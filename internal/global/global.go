@@ -1,16 +1,26 @@
 package global
 
 import (
+	"reflect"
 	"sync"
+	"sync/atomic"
 
 	"yourapp/pkg/config"
 )
 
 var (
-	configOnce sync.Once
-	appConfig  *config.Config
+	appConfig atomic.Pointer[config.Config]
+
+	subscribersMu sync.Mutex
+	subscribers   []subscriber
 )
 
+// subscriber is called when the section at path changes
+type subscriber struct {
+	path string
+	fn   func(old, new *config.Config)
+}
+
 // Config represents the application configuration
 type Config struct {
 	App      config.AppConfig      `yaml:"app"`
@@ -26,14 +36,37 @@ func Init() {
 	// Currently, configuration is loaded in bootstrap.Start()
 }
 
-// SetConfig sets the global configuration
+// SetConfig sets the global configuration. Replacing it notifies the subscribers of the
+// sections that changed.
 func SetConfig(cfg *config.Config) {
-	configOnce.Do(func() {
-		appConfig = cfg
-	})
+	old := appConfig.Swap(cfg)
+	if old == nil || cfg == nil {
+		return
+	}
+
+	subscribersMu.Lock()
+	subs := make([]subscriber, len(subscribers))
+	copy(subs, subscribers)
+	subscribersMu.Unlock()
+
+	for _, s := range subs {
+		before, _ := config.Lookup(old, s.path)
+		after, _ := config.Lookup(cfg, s.path)
+		if !reflect.DeepEqual(before, after) {
+			s.fn(old, cfg)
+		}
+	}
 }
 
 // GetConfig returns the global configuration
 func GetConfig() *config.Config {
-	return appConfig
+	return appConfig.Load()
+}
+
+// Subscribe calls fn with the previous and new configuration whenever SetConfig changes the
+// section or setting at path, such as "logging" or "cache.redis.pool_size"
+func Subscribe(path string, fn func(old, new *config.Config)) {
+	subscribersMu.Lock()
+	defer subscribersMu.Unlock()
+	subscribers = append(subscribers, subscriber{path: path, fn: fn})
 }
@@ -3,6 +3,7 @@ package redisx
 import (
 	"context"
 	"fmt"
+	"sync"
 	"time"
 
 	"github.com/go-redis/redis/v8"
@@ -10,12 +11,49 @@ import (
 )
 
 var (
+	mu     sync.RWMutex
 	client *redis.Client
 )
 
+// closeGrace is how long a replaced client stays open for the commands still using it
+const closeGrace = 30 * time.Second
+
 // Init initializes the Redis connection
 func Init(ctx context.Context, cfg config.RedisConfig) error {
-	client = redis.NewClient(&redis.Options{
+	c, err := newClient(ctx, cfg)
+	if err != nil {
+		return err
+	}
+
+	mu.Lock()
+	client = c
+	mu.Unlock()
+	return nil
+}
+
+// Reconfigure replaces the client with one using the pool settings of cfg. go-redis cannot
+// resize the pool of a client, so a new client is connected and the old one is closed once
+// the commands in progress had time to finish. Callers holding the old client instead of
+// calling GetClient get "redis: client is closed" after that.
+func Reconfigure(ctx context.Context, cfg config.RedisConfig) error {
+	c, err := newClient(ctx, cfg)
+	if err != nil {
+		return err
+	}
+
+	mu.Lock()
+	old := client
+	client = c
+	mu.Unlock()
+
+	if old != nil {
+		time.AfterFunc(closeGrace, func() { _ = old.Close() })
+	}
+	return nil
+}
+
+func newClient(ctx context.Context, cfg config.RedisConfig) (*redis.Client, error) {
+	c := redis.NewClient(&redis.Options{
 		Addr:         fmt.Sprintf("%s:%d", cfg.Host, cfg.Port),
 		Password:     cfg.Password,
 		DB:           cfg.Database,
@@ -25,44 +63,57 @@ func Init(ctx context.Context, cfg config.RedisConfig) error {
 	})
 
 	// Test the connection
-	_, err := client.Ping(ctx).Result()
+	_, err := c.Ping(ctx).Result()
 	if err != nil {
-		return fmt.Errorf("failed to connect to Redis: %w", err)
+		_ = c.Close()
+		return nil, fmt.Errorf("failed to connect to Redis: %w", err)
 	}
 
-	return nil
+	return c, nil
 }
 
-// GetClient returns the Redis client
+// GetClient returns the Redis client. Call it for every use rather than keeping the result:
+// Reconfigure replaces the client and closes the previous one after closeGrace.
 func GetClient() *redis.Client {
+	mu.RLock()
+	defer mu.RUnlock()
 	return client
 }
 
 // Close closes the Redis connection
 func Close() error {
-	if client != nil {
-		return client.Close()
+	if c := GetClient(); c != nil {
+		return c.Close()
 	}
 	return nil
 }
 
+// Health checks the health of the Redis connection
+func Health(ctx context.Context) error {
+	c := GetClient()
+	if c == nil {
+		return fmt.Errorf("Redis client not initialized")
+	}
+	return c.Ping(ctx).Err()
+}
+
 // Set sets a key-value pair with expiration
 func Set(ctx context.Context, key string, value interface{}, expiration time.Duration) error {
-	return client.Set(ctx, key, value, expiration).Err()
+	return GetClient().Set(ctx, key, value, expiration).Err()
 }
 
 // Get gets a value by key
 func Get(ctx context.Context, key string) (string, error) {
-	return client.Get(ctx, key).Result()
+	return GetClient().Get(ctx, key).Result()
 }
 
 // Del deletes a key
 func Del(ctx context.Context, key string) error {
-	return client.Del(ctx, key).Err()
+	return GetClient().Del(ctx, key).Err()
 }
 
 // Exists checks if a key exists
 func Exists(ctx context.Context, key string) (bool, error) {
-	result, err := client.Exists(ctx, key).Result()
+	result, err := GetClient().Exists(ctx, key).Result()
 	return result > 0, err
 }
@@ -3,6 +3,9 @@ package cli
 import (
 	"fmt"
 	"os"
+	"text/tabwriter"
+
+	"yourapp/pkg/config"
 
 	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
@@ -10,15 +13,16 @@ import (
 
 // Flags represents command line flags
 type Flags struct {
-	ConfigFile string
-	LogLevel   string
-	LogFormat  string
-	LogOutput  string
-	ServerHost string
-	ServerPort int
-	Env        string
-	Help       bool
-	Version    bool
+	ConfigFile    string
+	LogLevel      string
+	LogFormat     string
+	LogOutput     string
+	ServerHost    string
+	ServerPort    int
+	Env           string
+	ConfigSources bool
+	Help          bool
+	Version       bool
 }
 
 // ParseFlags parses command line flags
@@ -33,6 +37,7 @@ func ParseFlags() *Flags {
 	pflag.StringVarP(&flags.ServerHost, "host", "H", "", "Server host")
 	pflag.IntVarP(&flags.ServerPort, "port", "p", 0, "Server port")
 	pflag.StringVarP(&flags.Env, "env", "e", "", "Environment (development, staging, production)")
+	pflag.BoolVar(&flags.ConfigSources, "config-sources", false, "Print each effective setting with the layer it came from and exit")
 	pflag.BoolVarP(&flags.Help, "help", "h", false, "Show help message")
 	pflag.BoolVarP(&flags.Version, "version", "v", false, "Show version information")
 
@@ -57,34 +62,22 @@ func ParseFlags() *Flags {
 	return flags
 }
 
-// bindFlags binds command line flags to viper
+// bindFlags binds command line flags to viper. Flags only override the other layers when
+// they are given.
 func bindFlags(flags *Flags) {
 	if flags.ConfigFile != "" {
 		viper.SetConfigFile(flags.ConfigFile)
 	}
 
-	if flags.LogLevel != "" {
-		viper.Set("logging.level", flags.LogLevel)
-	}
-
-	if flags.LogFormat != "" {
-		viper.Set("logging.format", flags.LogFormat)
-	}
-
-	if flags.LogOutput != "" {
-		viper.Set("logging.output", flags.LogOutput)
-	}
-
-	if flags.ServerHost != "" {
-		viper.Set("server.host", flags.ServerHost)
-	}
-
-	if flags.ServerPort != 0 {
-		viper.Set("server.port", flags.ServerPort)
-	}
-
-	if flags.Env != "" {
-		viper.Set("app.env", flags.Env)
+	for key, name := range map[string]string{
+		"logging.level":  "log-level",
+		"logging.format": "log-format",
+		"logging.output": "log-output",
+		"server.host":    "host",
+		"server.port":    "port",
+		"app.env":        "env",
+	} {
+		_ = config.BindFlag(key, pflag.Lookup(name))
 	}
 }
 
@@ -94,19 +87,23 @@ func showHelp() {
 	fmt.Println("Options:")
 	pflag.PrintDefaults()
 	fmt.Println("\nEnvironment Variables:")
-	fmt.Println("  APP_CONFIG_FILE     Path to configuration file")
-	fmt.Println("  APP_LOG_LEVEL       Log level (debug, info, warn, error)")
-	fmt.Println("  APP_LOG_FORMAT      Log format (json, text)")
-	fmt.Println("  APP_LOG_OUTPUT      Log output (stdout, stderr, file)")
-	fmt.Println("  APP_SERVER_HOST     Server host")
-	fmt.Println("  APP_SERVER_PORT     Server port")
-	fmt.Println("  APP_ENV             Environment (development, staging, production)")
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "  %s\tPath to configuration file\n", config.ConfigFileEnv)
+	for _, e := range config.EnvVars() {
+		fmt.Fprintf(tw, "  %s\t%s\n", e.Name, e.Description)
+	}
+	_ = tw.Flush()
 	fmt.Println("\nConfiguration:")
 	fmt.Println("  Configuration can be provided via:")
 	fmt.Println("  - Command line flags (highest priority)")
 	fmt.Println("  - Environment variables")
-	fmt.Println("  - Configuration file")
+	fmt.Println("  - config.local.yaml")
+	fmt.Println("  - config.<env>.yaml, where env is app.env (--env)")
+	fmt.Println("  - config.yaml")
 	fmt.Println("  - Default values (lowest priority)")
+	fmt.Println("  Files are read from the directory of config.yaml and deep merged.")
+	fmt.Println("  Use --config-sources to see which layer each value came from.")
+	fmt.Printf("  Run %s config print|validate|init to inspect, check or generate configuration.\n", os.Args[0])
 }
 
 // showVersion displays version information
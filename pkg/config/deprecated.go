@@ -0,0 +1,89 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// deprecatedKeys maps settings that moved to their new path. The old keys and their
+// environment variables are still read, with a warning, so existing configurations keep
+// working.
+var deprecatedKeys = map[string]string{
+	"kafka.session_timeout":    "kafka.consumer.session_timeout",
+	"kafka.heartbeat_interval": "kafka.consumer.heartbeat_interval",
+}
+
+// deprecations holds the warnings of the last successful load; guarded by layersMu
+var deprecations []string
+
+// Deprecations returns a warning for every deprecated key or environment variable the
+// configuration was loaded from
+func Deprecations() []string {
+	layersMu.RLock()
+	defer layersMu.RUnlock()
+	return append([]string(nil), deprecations...)
+}
+
+// deprecatedFor returns the deprecated keys that moved to key
+func deprecatedFor(key string) []string {
+	var old []string
+	for from, to := range deprecatedKeys {
+		if to == key {
+			old = append(old, from)
+		}
+	}
+	sort.Strings(old)
+	return old
+}
+
+// migrateDeprecated sets the new path of every deprecated key found in the config files,
+// unless a higher or the same layer sets the new path itself. It returns the warnings for
+// the deprecated keys and environment variables in use.
+func migrateDeprecated(v *viper.Viper, read []layer) ([]string, error) {
+	olds := make([]string, 0, len(deprecatedKeys))
+	for old := range deprecatedKeys {
+		olds = append(olds, old)
+	}
+	sort.Strings(olds)
+
+	var warnings []string
+	for _, old := range olds {
+		key := deprecatedKeys[old]
+
+		for _, l := range read {
+			if l.v.IsSet(old) {
+				warnings = append(warnings, fmt.Sprintf("%s in %s is deprecated, use %s", old, l.file, key))
+			}
+		}
+		if name := envName(old); os.Getenv(name) != "" {
+			warnings = append(warnings, fmt.Sprintf("%s is deprecated, use %s", name, envName(key)))
+		}
+
+		for i := len(read) - 1; i >= 0; i-- {
+			if read[i].v.IsSet(key) {
+				break
+			}
+			if read[i].v.IsSet(old) {
+				if err := v.MergeConfigMap(nested(key, read[i].v.Get(old))); err != nil {
+					return nil, fmt.Errorf("failed to migrate %s: %w", old, err)
+				}
+				break
+			}
+		}
+	}
+	return warnings, nil
+}
+
+// nested builds the map a config file would hold for value at the dotted key
+func nested(key string, value any) map[string]any {
+	parts := strings.Split(key, ".")
+	m := map[string]any{parts[len(parts)-1]: value}
+	for i := len(parts) - 2; i >= 0; i-- {
+		m = map[string]any{parts[i]: m}
+	}
+	return m
+}
@@ -0,0 +1,214 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+)
+
+// Defaults returns the configuration made of the default values alone
+func Defaults() (*Config, error) {
+	v := viper.New()
+	setDefaults(v)
+
+	var cfg Config
+	if err := v.Unmarshal(&cfg, viper.DecodeHook(decodeHook)); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal defaults: %w", err)
+	}
+	return &cfg, nil
+}
+
+// Encode writes cfg as yaml or json with its secrets redacted. Keys are the mapstructure
+// names in declaration order and durations are written as strings such as 30s, so the
+// output can be read back as a config file.
+func Encode(w io.Writer, cfg *Config, format string) error {
+	tree := toTree(reflect.ValueOf(cfg.Redacted()).Elem(), false)
+
+	switch format {
+	case "yaml":
+		enc := yaml.NewEncoder(w)
+		enc.SetIndent(2)
+		if err := enc.Encode(tree); err != nil {
+			return fmt.Errorf("failed to encode config: %w", err)
+		}
+		return enc.Close()
+	case "json":
+		data, err := json.MarshalIndent(tree, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to encode config: %w", err)
+		}
+		_, err = fmt.Fprintf(w, "%s\n", data)
+		return err
+	default:
+		return fmt.Errorf("unknown format %q, expected yaml or json", format)
+	}
+}
+
+// WriteTemplate writes a config file holding every setting with its default value, commented
+// with its desc tag and allowed values
+func WriteTemplate(w io.Writer) error {
+	cfg, err := Defaults()
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(w, "# Application Configuration")
+	fmt.Fprintln(w, "#")
+	fmt.Fprintln(w, "# Every setting is shown with its default value. config.<env>.yaml and config.local.yaml")
+	fmt.Fprintln(w, "# next to this file are deep merged over it; each setting can also be set with an APP_")
+	fmt.Fprintln(w, "# environment variable such as APP_SERVER_PORT.")
+	fmt.Fprintln(w)
+
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(toTree(reflect.ValueOf(cfg).Elem(), true)); err != nil {
+		return fmt.Errorf("failed to encode config: %w", err)
+	}
+	return enc.Close()
+}
+
+// mapping keeps the keys of a struct in declaration order when encoded
+type mapping []entry
+
+type entry struct {
+	key     string
+	value   any
+	comment string
+}
+
+// toTree converts a struct into a mapping keyed by mapstructure names, with comments taken
+// from the tags when comments is set
+func toTree(v reflect.Value, comments bool) mapping {
+	var m mapping
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		name := strings.Split(sf.Tag.Get("mapstructure"), ",")[0]
+		if name == "" || name == "-" {
+			continue
+		}
+
+		e := entry{key: name, value: plain(v.Field(i), comments)}
+		if comments {
+			e.comment = comment(sf.Tag)
+		}
+		m = append(m, e)
+	}
+	return m
+}
+
+// comment describes a setting from its desc, validate and secret tags
+func comment(tag reflect.StructTag) string {
+	c := describe(tag)
+	if tag.Get("secret") == "true" {
+		c += "; may be a secret reference such as ${env:NAME}"
+	}
+	return c
+}
+
+// plain converts a setting into values that encode the way config files are written
+func plain(v reflect.Value, comments bool) any {
+	if d, ok := v.Interface().(time.Duration); ok {
+		return d.String()
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		return toTree(v, comments)
+	case reflect.Slice:
+		list := make([]any, v.Len())
+		for i := range list {
+			list[i] = plain(v.Index(i), comments)
+		}
+		return list
+	case reflect.Map:
+		m := make(map[string]any, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			m[fmt.Sprint(iter.Key().Interface())] = plain(iter.Value(), comments)
+		}
+		return m
+	case reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		return plain(v.Elem(), comments)
+	default:
+		return v.Interface()
+	}
+}
+
+// MarshalYAML implements yaml.Marshaler
+func (m mapping) MarshalYAML() (any, error) {
+	return m.node()
+}
+
+// node builds the mapping node directly; encoding nested mappings through yaml.Node.Encode
+// would lose the line comments of their values
+func (m mapping) node() (*yaml.Node, error) {
+	node := &yaml.Node{Kind: yaml.MappingNode}
+	for _, e := range m {
+		key := &yaml.Node{Kind: yaml.ScalarNode, Value: e.key}
+
+		if nested, ok := e.value.(mapping); ok {
+			value, err := nested.node()
+			if err != nil {
+				return nil, err
+			}
+			key.HeadComment = e.comment
+			node.Content = append(node.Content, key, value)
+			continue
+		}
+
+		value := &yaml.Node{}
+		if err := value.Encode(e.value); err != nil {
+			return nil, err
+		}
+		if value.Kind == yaml.SequenceNode && isScalars(value) {
+			value.Style = yaml.FlowStyle
+		}
+		value.LineComment = e.comment
+		node.Content = append(node.Content, key, value)
+	}
+	return node, nil
+}
+
+func isScalars(seq *yaml.Node) bool {
+	for _, n := range seq.Content {
+		if n.Kind != yaml.ScalarNode {
+			return false
+		}
+	}
+	return true
+}
+
+// MarshalJSON implements json.Marshaler
+func (m mapping) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, e := range m {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, err := json.Marshal(e.key)
+		if err != nil {
+			return nil, err
+		}
+		value, err := json.Marshal(e.value)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,90 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/mitchellh/mapstructure"
+	"github.com/spf13/viper"
+)
+
+// ConfigFileEnv names the config file when the --config flag is not given
+const ConfigFileEnv = "APP_CONFIG_FILE"
+
+// EnvVar is the environment variable that sets a setting
+type EnvVar struct {
+	Name string
+	Path string
+	// Description is the desc tag of the setting followed by its allowed values, if limited
+	Description string
+}
+
+// EnvVars lists the environment variable of every setting, in declaration order. Names are
+// APP_ followed by the upper-cased mapstructure path with dots replaced by underscores, such
+// as APP_LOGGING_LEVEL for logging.level.
+func EnvVars() []EnvVar {
+	var vars []EnvVar
+	for _, f := range Fields(&Config{}) {
+		vars = append(vars, EnvVar{Name: envName(f.Path), Path: f.Path, Description: describe(f.Tag)})
+	}
+	return vars
+}
+
+// describe returns the desc tag of a setting followed by the values its validate tag allows
+func describe(tag reflect.StructTag) string {
+	desc := tag.Get("desc")
+	for _, rule := range strings.Split(tag.Get("validate"), ",") {
+		if allowed, ok := strings.CutPrefix(rule, "oneof="); ok {
+			desc += " (" + strings.Join(strings.Fields(allowed), ", ") + ")"
+		}
+	}
+	return desc
+}
+
+// envName returns the environment variable read for the setting at key
+func envName(key string) string {
+	return "APP_" + strings.ToUpper(strings.ReplaceAll(key, ".", "_"))
+}
+
+// bindEnv binds every setting of v to its environment variable. Unlike AutomaticEnv this
+// also covers settings with no default or file entry, which Unmarshal would otherwise skip.
+func bindEnv(v *viper.Viper) error {
+	for _, e := range EnvVars() {
+		// The variables of deprecated keys are read when the new one is not set
+		names := []string{e.Path, e.Name}
+		for _, old := range deprecatedFor(e.Path) {
+			names = append(names, envName(old))
+		}
+		if err := v.BindEnv(names...); err != nil {
+			return fmt.Errorf("failed to bind %s: %w", e.Name, err)
+		}
+	}
+	return nil
+}
+
+// decodeHook extends viper's default hooks, which parse durations and comma separated
+// lists, with JSON for maps and lists of objects set from environment variables
+var decodeHook = mapstructure.ComposeDecodeHookFunc(
+	jsonStringHook,
+	mapstructure.StringToTimeDurationHookFunc(),
+	mapstructure.StringToSliceHookFunc(","),
+)
+
+// jsonStringHook decodes a string holding JSON into a map or a list of objects, such as
+// APP_KAFKA_EXTRA='{"client.id":"yourapp"}'
+func jsonStringHook(from, to reflect.Type, data any) (any, error) {
+	if from.Kind() != reflect.String {
+		return data, nil
+	}
+	if to.Kind() != reflect.Map && (to.Kind() != reflect.Slice || to.Elem().Kind() != reflect.Struct) {
+		return data, nil
+	}
+
+	var v any
+	if err := json.Unmarshal([]byte(data.(string)), &v); err != nil {
+		return nil, fmt.Errorf("expected JSON for %s: %w", to, err)
+	}
+	return v, nil
+}
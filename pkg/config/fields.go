@@ -0,0 +1,69 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+)
+
+// Field is a leaf setting of Config: a scalar, slice or map, with the dotted mapstructure
+// path used in config files, such as cache.redis.pool_size
+type Field struct {
+	Path  string
+	Tag   reflect.StructTag
+	Value reflect.Value
+}
+
+// Fields returns the settings of cfg in declaration order. The values are addressable, so
+// they can be set through Value.
+func Fields(cfg *Config) []Field {
+	var fields []Field
+	walkFields("", reflect.ValueOf(cfg).Elem(), func(f Field) {
+		fields = append(fields, f)
+	})
+	return fields
+}
+
+func walkFields(prefix string, v reflect.Value, fn func(Field)) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		name := strings.Split(sf.Tag.Get("mapstructure"), ",")[0]
+		if name == "" || name == "-" {
+			continue
+		}
+		path := name
+		if prefix != "" {
+			path = prefix + "." + name
+		}
+
+		fv := v.Field(i)
+		if fv.Kind() == reflect.Struct {
+			walkFields(path, fv, fn)
+			continue
+		}
+		fn(Field{Path: path, Tag: sf.Tag, Value: fv})
+	}
+}
+
+// Lookup returns the value at a dotted path, which may name a section such as cache.redis
+// or a single setting
+func Lookup(cfg *Config, path string) (any, bool) {
+	v := reflect.ValueOf(cfg).Elem()
+	for _, name := range strings.Split(path, ".") {
+		if v.Kind() != reflect.Struct {
+			return nil, false
+		}
+		found := false
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			if strings.Split(t.Field(i).Tag.Get("mapstructure"), ",")[0] == name {
+				v, found = v.Field(i), true
+				break
+			}
+		}
+		if !found {
+			return nil, false
+		}
+	}
+	return v.Interface(), true
+}
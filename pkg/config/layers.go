@@ -0,0 +1,187 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"text/tabwriter"
+
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+// Settings are layered from lowest to highest precedence: defaults, config.yaml,
+// config.<env>.yaml, config.local.yaml, environment variables and command line flags. Files
+// are deep merged, so a layer only needs the keys it overrides.
+
+// layer is a config file read into its own viper instance, kept to report where values
+// came from
+type layer struct {
+	file string
+	v    *viper.Viper
+}
+
+var (
+	layersMu sync.RWMutex
+	layers   []layer
+	flags    = map[string]*pflag.Flag{}
+)
+
+// BindFlag makes a command line flag set the setting at key when it is given on the
+// command line, above every other layer
+func BindFlag(key string, flag *pflag.Flag) error {
+	if err := viper.BindPFlag(key, flag); err != nil {
+		return fmt.Errorf("failed to bind flag for %s: %w", key, err)
+	}
+	layersMu.Lock()
+	defer layersMu.Unlock()
+	flags[key] = flag
+	return nil
+}
+
+// layerFiles returns the files merged over base for env, in order: <name>.<env><ext> and
+// <name>.local<ext> next to base
+func layerFiles(base, env string) []string {
+	ext := filepath.Ext(base)
+	stem := strings.TrimSuffix(base, ext)
+
+	var files []string
+	if env != "" {
+		files = append(files, stem+"."+env+ext)
+	}
+	return append(files, stem+".local"+ext)
+}
+
+// bindFlags binds the flags registered with BindFlag to v
+func bindFlags(v *viper.Viper) error {
+	layersMu.RLock()
+	defer layersMu.RUnlock()
+
+	for key, flag := range flags {
+		if err := v.BindPFlag(key, flag); err != nil {
+			return fmt.Errorf("failed to bind flag for %s: %w", key, err)
+		}
+	}
+	return nil
+}
+
+// readLayers reads the config file into v, then deep merges the files layered over it that
+// exist. The environment is app.env as set by the config file, environment variables or
+// flags. It returns the files read, or none when no config file was found.
+func readLayers(v *viper.Viper) ([]layer, error) {
+	if err := v.ReadInConfig(); err != nil {
+		if _, ok := err.(viper.ConfigFileNotFoundError); !ok {
+			return nil, fmt.Errorf("failed to read config file: %w", err)
+		}
+		// Config file not found, use defaults and environment variables
+		return nil, nil
+	}
+
+	base := v.ConfigFileUsed()
+	bv, err := readFile(base)
+	if err != nil {
+		return nil, err
+	}
+	read := []layer{{file: base, v: bv}}
+
+	for _, file := range layerFiles(base, v.GetString("app.env")) {
+		if _, err := os.Stat(file); errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		lv, err := readFile(file)
+		if err != nil {
+			return nil, err
+		}
+		if err := v.MergeConfigMap(lv.AllSettings()); err != nil {
+			return nil, fmt.Errorf("failed to merge config file %s: %w", file, err)
+		}
+		read = append(read, layer{file: file, v: lv})
+	}
+	return read, nil
+}
+
+// readFile reads a single config file into a new viper instance
+func readFile(file string) (*viper.Viper, error) {
+	v := viper.New()
+	v.SetConfigFile(file)
+	if err := v.ReadInConfig(); err != nil {
+		return nil, fmt.Errorf("failed to read config file %s: %w", file, err)
+	}
+	return v, nil
+}
+
+func setLayers(read []layer, warnings []string) {
+	layersMu.Lock()
+	defer layersMu.Unlock()
+	layers = read
+	deprecations = warnings
+}
+
+// Files returns the config files the configuration was read from, lowest precedence first
+func Files() []string {
+	layersMu.RLock()
+	defer layersMu.RUnlock()
+
+	files := make([]string, len(layers))
+	for i, l := range layers {
+		files[i] = l.file
+	}
+	return files
+}
+
+// Origin is the effective value of a setting and the layer it came from
+type Origin struct {
+	Path  string
+	Value any
+	// Source is "default", the path of a config file, "env <NAME>" or "flag --<name>"
+	Source string
+}
+
+// Origins reports every setting of cfg with the highest layer that sets it. Secrets are
+// redacted.
+func Origins(cfg *Config) []Origin {
+	layersMu.RLock()
+	defer layersMu.RUnlock()
+
+	var origins []Origin
+	for _, f := range Fields(cfg.Redacted()) {
+		origins = append(origins, Origin{Path: f.Path, Value: f.Value.Interface(), Source: source(f.Path)})
+	}
+	return origins
+}
+
+// source returns the highest layer that sets key; layersMu must be held
+func source(key string) string {
+	if flag, ok := flags[key]; ok && flag.Changed {
+		return "flag --" + flag.Name
+	}
+	keys := append([]string{key}, deprecatedFor(key)...)
+	for _, k := range keys {
+		if name := envName(k); os.Getenv(name) != "" {
+			return "env " + name
+		}
+	}
+	for i := len(layers) - 1; i >= 0; i-- {
+		for _, k := range keys {
+			if layers[i].v.IsSet(k) {
+				return layers[i].file
+			}
+		}
+	}
+	return "default"
+}
+
+// WriteOrigins writes the origins of cfg as a table
+func WriteOrigins(w io.Writer, cfg *Config) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "SETTING\tVALUE\tSOURCE")
+	for _, o := range Origins(cfg) {
+		fmt.Fprintf(tw, "%s\t%v\t%s\n", o.Path, o.Value, o.Source)
+	}
+	return tw.Flush()
+}
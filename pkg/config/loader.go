@@ -1,14 +1,17 @@
 package config
 
 import (
+	"context"
 	"fmt"
-	"strings"
+	"os"
 	"time"
 
 	"github.com/spf13/viper"
 )
 
-// Config represents the application configuration
+// Config represents the application configuration. Settings tagged reload:"live" are applied
+// while running when the config file changes; the others need a restart. The desc tag
+// describes a setting in the help output.
 type Config struct {
 	App           AppConfig           `mapstructure:"app"`
 	Server        ServerConfig        `mapstructure:"server"`
@@ -16,22 +19,24 @@ type Config struct {
 	Cache         CacheConfig         `mapstructure:"cache"`
 	Elasticsearch ElasticsearchConfig `mapstructure:"elasticsearch"`
 	Kafka         KafkaConfig         `mapstructure:"kafka"`
+	Outbox        OutboxConfig        `mapstructure:"outbox"`
+	Secrets       SecretsConfig       `mapstructure:"secrets"`
 	Logging       LoggingConfig       `mapstructure:"logging"`
 }
 
 // AppConfig represents application configuration
 type AppConfig struct {
-	Name    string `mapstructure:"name"`
-	Version string `mapstructure:"version"`
-	Env     string `mapstructure:"env"`
+	Name    string `mapstructure:"name" desc:"Application name"`
+	Version string `mapstructure:"version" desc:"Application version"`
+	Env     string `mapstructure:"env" desc:"Environment; selects config.<env>.yaml"`
 }
 
 // ServerConfig represents server configuration
 type ServerConfig struct {
-	Host         string        `mapstructure:"host"`
-	Port         int           `mapstructure:"port"`
-	ReadTimeout  time.Duration `mapstructure:"read_timeout"`
-	WriteTimeout time.Duration `mapstructure:"write_timeout"`
+	Host         string        `mapstructure:"host" desc:"Server host"`
+	Port         int           `mapstructure:"port" validate:"port" desc:"Server port"`
+	ReadTimeout  time.Duration `mapstructure:"read_timeout" validate:"min=0" desc:"Maximum duration for reading a request"`
+	WriteTimeout time.Duration `mapstructure:"write_timeout" validate:"min=0" desc:"Maximum duration for writing a response"`
 }
 
 // DatabaseConfig represents database configuration
@@ -42,32 +47,32 @@ type DatabaseConfig struct {
 
 // MySQLConfig represents MySQL configuration
 type MySQLConfig struct {
-	Enabled         bool          `mapstructure:"enabled"`
-	Host            string        `mapstructure:"host"`
-	Port            int           `mapstructure:"port"`
-	Username        string        `mapstructure:"username"`
-	Password        string        `mapstructure:"password"`
-	Database        string        `mapstructure:"database"`
-	Charset         string        `mapstructure:"charset"`
-	ParseTime       bool          `mapstructure:"parse_time"`
-	Loc             string        `mapstructure:"loc"`
-	MaxIdleConns    int           `mapstructure:"max_idle_conns"`
-	MaxOpenConns    int           `mapstructure:"max_open_conns"`
-	ConnMaxLifetime time.Duration `mapstructure:"conn_max_lifetime"`
+	Enabled         bool          `mapstructure:"enabled" desc:"Connect to MySQL"`
+	Host            string        `mapstructure:"host" desc:"MySQL host"`
+	Port            int           `mapstructure:"port" validate:"port" desc:"MySQL port"`
+	Username        string        `mapstructure:"username" desc:"MySQL user"`
+	Password        string        `mapstructure:"password" secret:"true" desc:"MySQL password"`
+	Database        string        `mapstructure:"database" desc:"MySQL database"`
+	Charset         string        `mapstructure:"charset" desc:"Connection character set"`
+	ParseTime       bool          `mapstructure:"parse_time" desc:"Scan DATE and DATETIME into time.Time"`
+	Loc             string        `mapstructure:"loc" desc:"Time zone for parsed times"`
+	MaxIdleConns    int           `mapstructure:"max_idle_conns" validate:"min=0" desc:"Maximum idle connections"`
+	MaxOpenConns    int           `mapstructure:"max_open_conns" validate:"min=0" desc:"Maximum open connections"`
+	ConnMaxLifetime time.Duration `mapstructure:"conn_max_lifetime" validate:"min=0" desc:"Maximum time a connection is reused"`
 }
 
 // PostgreSQLConfig represents PostgreSQL configuration
 type PostgreSQLConfig struct {
-	Enabled         bool          `mapstructure:"enabled"`
-	Host            string        `mapstructure:"host"`
-	Port            int           `mapstructure:"port"`
-	Username        string        `mapstructure:"username"`
-	Password        string        `mapstructure:"password"`
-	Database        string        `mapstructure:"database"`
-	SSLMode         string        `mapstructure:"sslmode"`
-	MaxIdleConns    int           `mapstructure:"max_idle_conns"`
-	MaxOpenConns    int           `mapstructure:"max_open_conns"`
-	ConnMaxLifetime time.Duration `mapstructure:"conn_max_lifetime"`
+	Enabled         bool          `mapstructure:"enabled" desc:"Connect to PostgreSQL"`
+	Host            string        `mapstructure:"host" desc:"PostgreSQL host"`
+	Port            int           `mapstructure:"port" validate:"port" desc:"PostgreSQL port"`
+	Username        string        `mapstructure:"username" desc:"PostgreSQL user"`
+	Password        string        `mapstructure:"password" secret:"true" desc:"PostgreSQL password"`
+	Database        string        `mapstructure:"database" desc:"PostgreSQL database"`
+	SSLMode         string        `mapstructure:"sslmode" validate:"oneof=disable allow prefer require verify-ca verify-full" desc:"SSL mode"`
+	MaxIdleConns    int           `mapstructure:"max_idle_conns" validate:"min=0" desc:"Maximum idle connections"`
+	MaxOpenConns    int           `mapstructure:"max_open_conns" validate:"min=0" desc:"Maximum open connections"`
+	ConnMaxLifetime time.Duration `mapstructure:"conn_max_lifetime" validate:"min=0" desc:"Maximum time a connection is reused"`
 }
 
 // CacheConfig represents cache configuration
@@ -77,155 +82,393 @@ type CacheConfig struct {
 
 // RedisConfig represents Redis configuration
 type RedisConfig struct {
-	Enabled      bool          `mapstructure:"enabled"`
-	Host         string        `mapstructure:"host"`
-	Port         int           `mapstructure:"port"`
-	Password     string        `mapstructure:"password"`
-	Database     int           `mapstructure:"database"`
-	PoolSize     int           `mapstructure:"pool_size"`
-	MinIdleConns int           `mapstructure:"min_idle_conns"`
-	MaxConnAge   time.Duration `mapstructure:"max_conn_age"`
+	Enabled      bool          `mapstructure:"enabled" desc:"Connect to Redis"`
+	Host         string        `mapstructure:"host" desc:"Redis host"`
+	Port         int           `mapstructure:"port" validate:"port" desc:"Redis port"`
+	Password     string        `mapstructure:"password" secret:"true" desc:"Redis password"`
+	Database     int           `mapstructure:"database" validate:"min=0" desc:"Redis database number"`
+	PoolSize     int           `mapstructure:"pool_size" reload:"live" validate:"min=0" desc:"Maximum connections; applied without a restart"`
+	MinIdleConns int           `mapstructure:"min_idle_conns" reload:"live" validate:"min=0" desc:"Idle connections kept open; applied without a restart"`
+	MaxConnAge   time.Duration `mapstructure:"max_conn_age" validate:"min=0" desc:"Maximum time a connection is reused"`
 }
 
 // ElasticsearchConfig represents Elasticsearch configuration
 type ElasticsearchConfig struct {
-	Enabled             bool          `mapstructure:"enabled"`
-	Host                string        `mapstructure:"host"`
-	Port                int           `mapstructure:"port"`
-	Username            string        `mapstructure:"username"`
-	Password            string        `mapstructure:"password"`
-	MaxIdleConnsPerHost int           `mapstructure:"max_idle_conns_per_host"`
-	Timeout             time.Duration `mapstructure:"timeout"`
+	Enabled bool `mapstructure:"enabled" desc:"Connect to Elasticsearch"`
+	// Addresses lists node URLs such as https://es1:9200; Host and Port are used only when
+	// both Addresses and CloudID are empty
+	Addresses []string `mapstructure:"addresses" desc:"Node URLs; host and port are used when this and cloud_id are empty"`
+	Host      string   `mapstructure:"host" desc:"Elasticsearch host"`
+	Port      int      `mapstructure:"port" validate:"port" desc:"Elasticsearch port"`
+	CloudID   string   `mapstructure:"cloud_id" desc:"Elastic Cloud deployment ID, instead of addresses"`
+	Username  string   `mapstructure:"username" desc:"Elasticsearch user"`
+	Password  string   `mapstructure:"password" secret:"true" desc:"Elasticsearch password"`
+	// APIKey is the base64 encoded "id:api_key"; it takes precedence over username and password
+	APIKey                string        `mapstructure:"api_key" secret:"true" desc:"Base64 encoded id:api_key; takes precedence over username and password"`
+	TLS                   ESTLSConfig   `mapstructure:"tls"`
+	MaxIdleConnsPerHost   int           `mapstructure:"max_idle_conns_per_host" validate:"min=0" desc:"Maximum idle connections per node"`
+	Timeout               time.Duration `mapstructure:"timeout" validate:"min=0" desc:"Dial, TLS handshake and response header timeout"`
+	MaxRetries            int           `mapstructure:"max_retries" validate:"min=-1" desc:"Attempts after the first on another node; -1 disables retries"`
+	RetryOnStatus         []int         `mapstructure:"retry_on_status" desc:"Response codes retried on the next node"`
+	RetryBackoff          time.Duration `mapstructure:"retry_backoff" validate:"min=0" desc:"Delay before a retry, doubled each time up to 10s"`
+	DiscoverNodesOnStart  bool          `mapstructure:"discover_nodes_on_start" desc:"Sniff the cluster nodes at startup"`
+	DiscoverNodesInterval time.Duration `mapstructure:"discover_nodes_interval" validate:"min=0" desc:"Sniff the cluster nodes this often; 0 disables"`
+	// TemplatesDir holds ILM policies, component templates and index templates applied at
+	// startup; empty disables it
+	TemplatesDir string       `mapstructure:"templates_dir" desc:"Directory of ILM policies, component and index templates applied at startup"`
+	Bulk         ESBulkConfig `mapstructure:"bulk"`
+}
+
+// ESTLSConfig represents TLS configuration for Elasticsearch connections
+type ESTLSConfig struct {
+	// Enabled selects https for Host and Port; Addresses carry their own scheme
+	Enabled bool   `mapstructure:"enabled" desc:"Use https for host and port"`
+	CAFile  string `mapstructure:"ca_file" desc:"CA certificate file"`
+	// CAFingerprint is the SHA-256 hex fingerprint of the CA certificate printed on the
+	// first start of Elasticsearch, an alternative to CAFile
+	CAFingerprint      string `mapstructure:"ca_fingerprint" desc:"SHA-256 hex fingerprint of the CA certificate, instead of ca_file"`
+	CertFile           string `mapstructure:"cert_file" desc:"Client certificate file"`
+	KeyFile            string `mapstructure:"key_file" desc:"Client key file"`
+	InsecureSkipVerify bool   `mapstructure:"insecure_skip_verify" desc:"Skip server certificate verification"`
+}
+
+// ESBulkConfig represents Elasticsearch bulk indexer configuration
+type ESBulkConfig struct {
+	Workers       int           `mapstructure:"workers" validate:"min=0" desc:"Bulk indexer workers; 0 uses the number of CPUs"`
+	FlushBytes    int           `mapstructure:"flush_bytes" validate:"min=0" desc:"Flush a worker's batch once it reaches this size"`
+	FlushInterval time.Duration `mapstructure:"flush_interval" validate:"min=0" desc:"Flush batches at least this often"`
+	MaxRetries    int           `mapstructure:"max_retries" validate:"min=0" desc:"Times an item rejected with 429 is retried"`
+	RetryBackoff  time.Duration `mapstructure:"retry_backoff" validate:"min=0" desc:"Delay before a retry, doubled each time"`
 }
 
 // KafkaConfig represents Kafka configuration
 type KafkaConfig struct {
-	Enabled           bool          `mapstructure:"enabled"`
-	Host              string        `mapstructure:"host"`
-	Port              int           `mapstructure:"port"`
-	Username          string        `mapstructure:"username"`
-	Password          string        `mapstructure:"password"`
-	SecurityProtocol  string        `mapstructure:"security_protocol"`
-	SASLMechanism     string        `mapstructure:"sasl_mechanism"`
-	SessionTimeout    time.Duration `mapstructure:"session_timeout"`
-	HeartbeatInterval time.Duration `mapstructure:"heartbeat_interval"`
+	Enabled bool     `mapstructure:"enabled" desc:"Connect to Kafka"`
+	Brokers []string `mapstructure:"brokers" desc:"Bootstrap brokers; host and port are used when this is empty"`
+	// Host and Port name a single broker; they are used only when Brokers is empty
+	Host             string         `mapstructure:"host" desc:"Kafka broker host"`
+	Port             int            `mapstructure:"port" validate:"port" desc:"Kafka broker port"`
+	Username         string         `mapstructure:"username" desc:"SASL user"`
+	Password         string         `mapstructure:"password" secret:"true" desc:"SASL password"`
+	SecurityProtocol string         `mapstructure:"security_protocol" validate:"oneof=PLAINTEXT SSL SASL_PLAINTEXT SASL_SSL" desc:"Security protocol"`
+	SASLMechanism    string         `mapstructure:"sasl_mechanism" validate:"oneof=PLAIN SCRAM-SHA-256 SCRAM-SHA-512 GSSAPI OAUTHBEARER" desc:"SASL mechanism"`
+	SSL              KafkaSSLConfig `mapstructure:"ssl"`
+	// Extra holds librdkafka properties passed unchanged to every client
+	Extra          map[string]any       `mapstructure:"extra" desc:"librdkafka properties for every client, as JSON in env vars"`
+	Producer       KafkaProducerConfig  `mapstructure:"producer"`
+	Consumer       KafkaConsumerConfig  `mapstructure:"consumer"`
+	Retry          KafkaRetryConfig     `mapstructure:"retry"`
+	Topics         []KafkaTopicConfig   `mapstructure:"topics" desc:"Topics created or updated at startup, as JSON in env vars"`
+	SchemaRegistry SchemaRegistryConfig `mapstructure:"schema_registry"`
+}
+
+// KafkaSSLConfig represents TLS configuration for Kafka connections
+type KafkaSSLConfig struct {
+	CAFile             string `mapstructure:"ca_file" desc:"CA certificate file"`
+	CertFile           string `mapstructure:"cert_file" desc:"Client certificate file for mutual TLS"`
+	KeyFile            string `mapstructure:"key_file" desc:"Client key file"`
+	KeyPassword        string `mapstructure:"key_password" secret:"true" desc:"Client key password"`
+	InsecureSkipVerify bool   `mapstructure:"insecure_skip_verify" desc:"Skip broker certificate verification"`
+}
+
+// KafkaProducerConfig represents Kafka producer configuration
+type KafkaProducerConfig struct {
+	Linger       time.Duration  `mapstructure:"linger" validate:"min=0" desc:"How long the producer waits to fill a batch"`
+	BatchSize    int            `mapstructure:"batch_size" validate:"min=0" desc:"Maximum batch size in bytes"`
+	Compression  string         `mapstructure:"compression" validate:"oneof=none gzip snappy lz4 zstd" desc:"Compression codec"`
+	Acks         string         `mapstructure:"acks" validate:"oneof=all -1 0 1" desc:"Broker acknowledgements required"`
+	Idempotence  bool           `mapstructure:"idempotence" desc:"Enable the idempotent producer"`
+	FlushTimeout time.Duration  `mapstructure:"flush_timeout" validate:"min=0" desc:"How long shutdown waits for queued messages"`
+	Extra        map[string]any `mapstructure:"extra" desc:"librdkafka properties for the producer, as JSON in env vars"`
+}
+
+// KafkaConsumerConfig represents Kafka consumer configuration
+type KafkaConsumerConfig struct {
+	GroupID           string         `mapstructure:"group_id" desc:"Consumer group"`
+	AutoOffsetReset   string         `mapstructure:"auto_offset_reset" validate:"oneof=earliest latest" desc:"Where to start when the group has no committed offset"`
+	SessionTimeout    time.Duration  `mapstructure:"session_timeout" validate:"min=0" desc:"Group session timeout"`
+	HeartbeatInterval time.Duration  `mapstructure:"heartbeat_interval" validate:"min=0" desc:"Group heartbeat interval"`
+	Workers           int            `mapstructure:"workers" validate:"min=0" desc:"Handler goroutines"`
+	MaxInFlight       int            `mapstructure:"max_in_flight" validate:"min=0" desc:"Unfinished messages per partition before it is paused"`
+	CommitInterval    time.Duration  `mapstructure:"commit_interval" validate:"min=0" desc:"How often offsets are committed"`
+	LagInterval       time.Duration  `mapstructure:"lag_interval" validate:"min=0" desc:"How often consumer lag is measured"`
+	LagThreshold      int64          `mapstructure:"lag_threshold" validate:"min=0" desc:"Lag that marks readiness degraded; 0 disables"`
+	Extra             map[string]any `mapstructure:"extra" desc:"librdkafka properties for the consumer, as JSON in env vars"`
+}
+
+// KafkaTopicConfig represents a topic that is created or updated at startup
+type KafkaTopicConfig struct {
+	Name              string            `mapstructure:"name" validate:"required"`
+	Partitions        int               `mapstructure:"partitions" validate:"min=1"`
+	ReplicationFactor int               `mapstructure:"replication_factor" validate:"min=1"`
+	Config            map[string]string `mapstructure:"config"`
+	RetryTopics       bool              `mapstructure:"retry_topics"`
+}
+
+// KafkaRetryConfig represents retry and dead-letter configuration for Kafka consumers
+type KafkaRetryConfig struct {
+	Enabled     bool            `mapstructure:"enabled" desc:"Route failed messages to retry and dead-letter topics"`
+	Attempts    int             `mapstructure:"attempts" validate:"min=0" desc:"In-process handler attempts per delivery"`
+	Backoff     time.Duration   `mapstructure:"backoff" validate:"min=0" desc:"Delay between in-process attempts"`
+	MaxBackoff  time.Duration   `mapstructure:"max_backoff" validate:"min=0" desc:"Maximum delay between in-process attempts"`
+	Delays      []time.Duration `mapstructure:"delays" desc:"Delay of each retry topic"`
+	RetrySuffix string          `mapstructure:"retry_suffix" desc:"Suffix of retry topics"`
+	DLQSuffix   string          `mapstructure:"dlq_suffix" desc:"Suffix of the dead-letter topic"`
+}
+
+// SchemaRegistryConfig represents Confluent Schema Registry configuration
+type SchemaRegistryConfig struct {
+	URL          string        `mapstructure:"url" desc:"Schema Registry URL; empty disables it"`
+	Username     string        `mapstructure:"username" desc:"Schema Registry user"`
+	Password     string        `mapstructure:"password" secret:"true" desc:"Schema Registry password"`
+	Timeout      time.Duration `mapstructure:"timeout" validate:"min=0" desc:"Request timeout"`
+	AutoRegister bool          `mapstructure:"auto_register" desc:"Register writer schemas instead of only looking them up"`
+}
+
+// OutboxConfig represents transactional outbox relay configuration
+type OutboxConfig struct {
+	Enabled         bool          `mapstructure:"enabled" desc:"Run the outbox relay"`
+	Database        string        `mapstructure:"database" validate:"oneof=mysql postgres" desc:"Database holding the outbox table"`
+	PollInterval    time.Duration `mapstructure:"poll_interval" validate:"min=0" desc:"How often pending rows are polled"`
+	BatchSize       int           `mapstructure:"batch_size" validate:"min=0" desc:"Rows relayed per poll"`
+	MaxAttempts     int           `mapstructure:"max_attempts" validate:"min=0" desc:"Attempts before a row is marked failed"`
+	Retention       time.Duration `mapstructure:"retention" validate:"min=0" desc:"Age after which sent rows are deleted"`
+	CleanupInterval time.Duration `mapstructure:"cleanup_interval" validate:"min=0" desc:"How often sent rows are cleaned up"`
+}
+
+// SecretsConfig represents the secret providers used to resolve references such as
+// ${vault:secret/data/db#password} in other settings
+type SecretsConfig struct {
+	Vault VaultConfig `mapstructure:"vault"`
+}
+
+// VaultConfig represents a Vault-compatible HTTP API; the vault provider is registered only
+// when Address is set
+type VaultConfig struct {
+	Address string        `mapstructure:"address" desc:"Vault-compatible HTTP API; enables ${vault:<path>#<field>} references"`
+	Token   string        `mapstructure:"token" secret:"true" desc:"Vault token"`
+	Timeout time.Duration `mapstructure:"timeout" validate:"min=0" desc:"Request timeout"`
 }
 
 // LoggingConfig represents logging configuration
 type LoggingConfig struct {
-	Level    string `mapstructure:"level"`
-	Format   string `mapstructure:"format"`
-	Output   string `mapstructure:"output"`
-	FilePath string `mapstructure:"file_path"`
+	Level    string `mapstructure:"level" reload:"live" validate:"oneof=debug info warn error fatal panic" desc:"Log level; applied without a restart"`
+	Format   string `mapstructure:"format" validate:"oneof=json text console" desc:"Log format"`
+	Output   string `mapstructure:"output" validate:"oneof=stdout stderr file" desc:"Log output"`
+	FilePath string `mapstructure:"file_path" desc:"Log file when output is file"`
 }
 
-// Load loads configuration using Viper
+// Load loads configuration using Viper, layering config.yaml, config.<env>.yaml,
+// config.local.yaml, environment variables and flags over the defaults
 func Load() (*Config, error) {
 	// Set default values
-	setDefaults()
+	setDefaults(viper.GetViper())
 
-	// Configure Viper
-	viper.SetConfigName("config")
+	// Configure Viper, unless a config file was set explicitly
 	viper.SetConfigType("yaml")
-	viper.AddConfigPath("./configs")
-	viper.AddConfigPath(".")
-	viper.AddConfigPath("/etc/yourapp")
-
-	// Enable reading from environment variables
-	viper.AutomaticEnv()
-	viper.SetEnvPrefix("APP")
-	viper.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
-
-	// Read config file
-	if err := viper.ReadInConfig(); err != nil {
-		if _, ok := err.(viper.ConfigFileNotFoundError); !ok {
-			return nil, fmt.Errorf("failed to read config file: %w", err)
-		}
-		// Config file not found, use defaults and environment variables
+	if file := os.Getenv(ConfigFileEnv); file != "" && viper.ConfigFileUsed() == "" {
+		viper.SetConfigFile(file)
+	}
+	if viper.ConfigFileUsed() == "" {
+		viper.SetConfigName("config")
+		viper.AddConfigPath("./configs")
+		viper.AddConfigPath(".")
+		viper.AddConfigPath("/etc/yourapp")
+	}
+
+	// Bind every setting to its environment variable, such as APP_LOGGING_LEVEL
+	if err := bindEnv(viper.GetViper()); err != nil {
+		return nil, err
+	}
+
+	return load(viper.GetViper())
+}
+
+// reload loads the configuration again from file into a new viper instance, so the global
+// one is never written while other goroutines read it
+func reload(file string) (*Config, error) {
+	v := viper.New()
+	setDefaults(v)
+	v.SetConfigFile(file)
+
+	if err := bindEnv(v); err != nil {
+		return nil, err
+	}
+	if err := bindFlags(v); err != nil {
+		return nil, err
+	}
+
+	return load(v)
+}
+
+// load reads the config files into v and decodes the result. The files read are recorded
+// for Files and Origins only once the configuration is valid.
+func load(v *viper.Viper) (*Config, error) {
+	read, err := readLayers(v)
+	if err != nil {
+		return nil, err
+	}
+	warnings, err := migrateDeprecated(v, read)
+	if err != nil {
+		return nil, err
 	}
 
 	// Unmarshal into struct
 	var config Config
-	if err := viper.Unmarshal(&config); err != nil {
+	if err := v.Unmarshal(&config, viper.DecodeHook(decodeHook)); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal config: %w", err)
 	}
+	normalize(&config)
 
+	// Replace secret references such as ${env:DB_PASS} with their values
+	if err := ResolveSecrets(context.Background(), &config); err != nil {
+		return nil, err
+	}
+
+	// Report every invalid setting and unknown key at once
+	files := make([]string, len(read))
+	for i, l := range read {
+		files[i] = l.file
+	}
+	if err := check(&config, files...); err != nil {
+		return nil, err
+	}
+
+	setLayers(read, warnings)
 	return &config, nil
 }
 
-// setDefaults sets default configuration values
-func setDefaults() {
+// setDefaults sets default configuration values on v
+func setDefaults(v *viper.Viper) {
 	// App defaults
-	viper.SetDefault("app.name", "yourapp")
-	viper.SetDefault("app.version", "1.0.0")
-	viper.SetDefault("app.env", "development")
+	v.SetDefault("app.name", "yourapp")
+	v.SetDefault("app.version", "1.0.0")
+	v.SetDefault("app.env", "development")
 
 	// Server defaults
-	viper.SetDefault("server.host", "localhost")
-	viper.SetDefault("server.port", 8080)
-	viper.SetDefault("server.read_timeout", "30s")
-	viper.SetDefault("server.write_timeout", "30s")
+	v.SetDefault("server.host", "localhost")
+	v.SetDefault("server.port", 8080)
+	v.SetDefault("server.read_timeout", "30s")
+	v.SetDefault("server.write_timeout", "30s")
 
 	// Database defaults
-	viper.SetDefault("database.mysql.enabled", false)
-	viper.SetDefault("database.mysql.host", "localhost")
-	viper.SetDefault("database.mysql.port", 3306)
-	viper.SetDefault("database.mysql.username", "root")
-	viper.SetDefault("database.mysql.password", "")
-	viper.SetDefault("database.mysql.database", "yourapp")
-	viper.SetDefault("database.mysql.charset", "utf8mb4")
-	viper.SetDefault("database.mysql.parse_time", true)
-	viper.SetDefault("database.mysql.loc", "Local")
-	viper.SetDefault("database.mysql.max_idle_conns", 10)
-	viper.SetDefault("database.mysql.max_open_conns", 100)
-	viper.SetDefault("database.mysql.conn_max_lifetime", "3600s")
-
-	viper.SetDefault("database.postgres.enabled", false)
-	viper.SetDefault("database.postgres.host", "localhost")
-	viper.SetDefault("database.postgres.port", 5432)
-	viper.SetDefault("database.postgres.username", "postgres")
-	viper.SetDefault("database.postgres.password", "")
-	viper.SetDefault("database.postgres.database", "yourapp")
-	viper.SetDefault("database.postgres.sslmode", "disable")
-	viper.SetDefault("database.postgres.max_idle_conns", 10)
-	viper.SetDefault("database.postgres.max_open_conns", 100)
-	viper.SetDefault("database.postgres.conn_max_lifetime", "3600s")
+	v.SetDefault("database.mysql.enabled", false)
+	v.SetDefault("database.mysql.host", "localhost")
+	v.SetDefault("database.mysql.port", 3306)
+	v.SetDefault("database.mysql.username", "root")
+	v.SetDefault("database.mysql.password", "")
+	v.SetDefault("database.mysql.database", "yourapp")
+	v.SetDefault("database.mysql.charset", "utf8mb4")
+	v.SetDefault("database.mysql.parse_time", true)
+	v.SetDefault("database.mysql.loc", "Local")
+	v.SetDefault("database.mysql.max_idle_conns", 10)
+	v.SetDefault("database.mysql.max_open_conns", 100)
+	v.SetDefault("database.mysql.conn_max_lifetime", "3600s")
+
+	v.SetDefault("database.postgres.enabled", false)
+	v.SetDefault("database.postgres.host", "localhost")
+	v.SetDefault("database.postgres.port", 5432)
+	v.SetDefault("database.postgres.username", "postgres")
+	v.SetDefault("database.postgres.password", "")
+	v.SetDefault("database.postgres.database", "yourapp")
+	v.SetDefault("database.postgres.sslmode", "disable")
+	v.SetDefault("database.postgres.max_idle_conns", 10)
+	v.SetDefault("database.postgres.max_open_conns", 100)
+	v.SetDefault("database.postgres.conn_max_lifetime", "3600s")
 
 	// Cache defaults
-	viper.SetDefault("cache.redis.enabled", false)
-	viper.SetDefault("cache.redis.host", "localhost")
-	viper.SetDefault("cache.redis.port", 6379)
-	viper.SetDefault("cache.redis.password", "")
-	viper.SetDefault("cache.redis.database", 0)
-	viper.SetDefault("cache.redis.pool_size", 10)
-	viper.SetDefault("cache.redis.min_idle_conns", 5)
-	viper.SetDefault("cache.redis.max_conn_age", "3600s")
+	v.SetDefault("cache.redis.enabled", false)
+	v.SetDefault("cache.redis.host", "localhost")
+	v.SetDefault("cache.redis.port", 6379)
+	v.SetDefault("cache.redis.password", "")
+	v.SetDefault("cache.redis.database", 0)
+	v.SetDefault("cache.redis.pool_size", 10)
+	v.SetDefault("cache.redis.min_idle_conns", 5)
+	v.SetDefault("cache.redis.max_conn_age", "3600s")
 
 	// Elasticsearch defaults
-	viper.SetDefault("elasticsearch.enabled", false)
-	viper.SetDefault("elasticsearch.host", "localhost")
-	viper.SetDefault("elasticsearch.port", 9200)
-	viper.SetDefault("elasticsearch.username", "")
-	viper.SetDefault("elasticsearch.password", "")
-	viper.SetDefault("elasticsearch.max_idle_conns_per_host", 10)
-	viper.SetDefault("elasticsearch.timeout", "30s")
+	v.SetDefault("elasticsearch.enabled", false)
+	v.SetDefault("elasticsearch.addresses", []string{})
+	v.SetDefault("elasticsearch.host", "localhost")
+	v.SetDefault("elasticsearch.port", 9200)
+	v.SetDefault("elasticsearch.cloud_id", "")
+	v.SetDefault("elasticsearch.username", "")
+	v.SetDefault("elasticsearch.password", "")
+	v.SetDefault("elasticsearch.api_key", "")
+	v.SetDefault("elasticsearch.tls.enabled", false)
+	v.SetDefault("elasticsearch.tls.ca_file", "")
+	v.SetDefault("elasticsearch.tls.ca_fingerprint", "")
+	v.SetDefault("elasticsearch.tls.cert_file", "")
+	v.SetDefault("elasticsearch.tls.key_file", "")
+	v.SetDefault("elasticsearch.tls.insecure_skip_verify", false)
+	v.SetDefault("elasticsearch.max_idle_conns_per_host", 10)
+	v.SetDefault("elasticsearch.timeout", "30s")
+	v.SetDefault("elasticsearch.max_retries", 3)
+	v.SetDefault("elasticsearch.retry_on_status", []int{429, 502, 503, 504})
+	v.SetDefault("elasticsearch.retry_backoff", "100ms")
+	v.SetDefault("elasticsearch.discover_nodes_on_start", false)
+	v.SetDefault("elasticsearch.discover_nodes_interval", "0s")
+	v.SetDefault("elasticsearch.templates_dir", "")
+	v.SetDefault("elasticsearch.bulk.workers", 0)
+	v.SetDefault("elasticsearch.bulk.flush_bytes", 5242880)
+	v.SetDefault("elasticsearch.bulk.flush_interval", "5s")
+	v.SetDefault("elasticsearch.bulk.max_retries", 3)
+	v.SetDefault("elasticsearch.bulk.retry_backoff", "1s")
 
 	// Kafka defaults
-	viper.SetDefault("kafka.enabled", false)
-	viper.SetDefault("kafka.host", "localhost")
-	viper.SetDefault("kafka.port", 9092)
-	viper.SetDefault("kafka.username", "")
-	viper.SetDefault("kafka.password", "")
-	viper.SetDefault("kafka.security_protocol", "PLAINTEXT")
-	viper.SetDefault("kafka.sasl_mechanism", "PLAIN")
-	viper.SetDefault("kafka.session_timeout", "30s")
-	viper.SetDefault("kafka.heartbeat_interval", "3s")
+	v.SetDefault("kafka.enabled", false)
+	v.SetDefault("kafka.host", "localhost")
+	v.SetDefault("kafka.port", 9092)
+	v.SetDefault("kafka.username", "")
+	v.SetDefault("kafka.password", "")
+	v.SetDefault("kafka.security_protocol", "PLAINTEXT")
+	v.SetDefault("kafka.sasl_mechanism", "PLAIN")
+	v.SetDefault("kafka.ssl.insecure_skip_verify", false)
+	v.SetDefault("kafka.producer.linger", "5ms")
+	v.SetDefault("kafka.producer.batch_size", 1000000)
+	v.SetDefault("kafka.producer.compression", "none")
+	v.SetDefault("kafka.producer.acks", "all")
+	v.SetDefault("kafka.producer.idempotence", true)
+	v.SetDefault("kafka.producer.flush_timeout", "10s")
+	v.SetDefault("kafka.consumer.group_id", "yourapp")
+	v.SetDefault("kafka.consumer.auto_offset_reset", "latest")
+	v.SetDefault("kafka.consumer.session_timeout", "30s")
+	v.SetDefault("kafka.consumer.heartbeat_interval", "3s")
+	v.SetDefault("kafka.consumer.workers", 4)
+	v.SetDefault("kafka.consumer.max_in_flight", 100)
+	v.SetDefault("kafka.consumer.commit_interval", "5s")
+	v.SetDefault("kafka.consumer.lag_interval", "15s")
+	v.SetDefault("kafka.consumer.lag_threshold", 10000)
+	v.SetDefault("kafka.retry.enabled", false)
+	v.SetDefault("kafka.retry.attempts", 3)
+	v.SetDefault("kafka.retry.backoff", "200ms")
+	v.SetDefault("kafka.retry.max_backoff", "5s")
+	v.SetDefault("kafka.retry.delays", []string{"10s", "1m", "10m"})
+	v.SetDefault("kafka.retry.retry_suffix", ".retry")
+	v.SetDefault("kafka.retry.dlq_suffix", ".dlq")
+	v.SetDefault("kafka.schema_registry.url", "")
+	v.SetDefault("kafka.schema_registry.timeout", "10s")
+	v.SetDefault("kafka.schema_registry.auto_register", false)
+
+	// Outbox defaults
+	v.SetDefault("outbox.enabled", false)
+	v.SetDefault("outbox.database", "mysql")
+	v.SetDefault("outbox.poll_interval", "1s")
+	v.SetDefault("outbox.batch_size", 100)
+	v.SetDefault("outbox.max_attempts", 10)
+	v.SetDefault("outbox.retention", "168h")
+	v.SetDefault("outbox.cleanup_interval", "1h")
+
+	// Secrets defaults
+	v.SetDefault("secrets.vault.address", "")
+	v.SetDefault("secrets.vault.token", "")
+	v.SetDefault("secrets.vault.timeout", "10s")
 
 	// Logging defaults
-	viper.SetDefault("logging.level", "info")
-	viper.SetDefault("logging.format", "json")
-	viper.SetDefault("logging.output", "stdout")
-	viper.SetDefault("logging.file_path", "logs/app.log")
+	v.SetDefault("logging.level", "info")
+	v.SetDefault("logging.format", "json")
+	v.SetDefault("logging.output", "stdout")
+	v.SetDefault("logging.file_path", "logs/app.log")
 }
 
 // GetString returns a string value from config
@@ -0,0 +1,93 @@
+package config
+
+import (
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/viper"
+)
+
+// watchDebounce is how long Watch waits for file events to stop before reloading, so a save
+// that truncates, writes and renames files is read once and complete
+const watchDebounce = 100 * time.Millisecond
+
+// Watch reloads the configuration whenever one of its layered config files is written,
+// created or removed, and calls onChange with the new configuration, or with the error when
+// it cannot be loaded or is invalid. Events are coalesced for watchDebounce before reloading.
+// Nothing is watched when no config file was read.
+func Watch(onChange func(*Config, error)) bool {
+	files := Files()
+	if len(files) == 0 {
+		return false
+	}
+	base := files[0]
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		onChange(nil, fmt.Errorf("failed to watch config files: %w", err))
+		return false
+	}
+	// Watch the directory rather than the files, so editors that replace files and layers
+	// created later are noticed
+	if err := watcher.Add(filepath.Dir(base)); err != nil {
+		watcher.Close()
+		onChange(nil, fmt.Errorf("failed to watch config files: %w", err))
+		return false
+	}
+
+	watched := map[string]bool{filepath.Clean(base): true}
+	for _, file := range layerFiles(base, viper.GetString("app.env")) {
+		watched[filepath.Clean(file)] = true
+	}
+
+	go func() {
+		defer watcher.Close()
+		var pending <-chan time.Time
+		for {
+			select {
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if !watched[filepath.Clean(event.Name)] || event.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Remove|fsnotify.Rename) == 0 {
+					continue
+				}
+				pending = time.After(watchDebounce)
+			case <-pending:
+				pending = nil
+				onChange(reload(base))
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				onChange(nil, fmt.Errorf("failed to watch config files: %w", err))
+			}
+		}
+	}()
+	return true
+}
+
+// ApplyLive returns current with the settings tagged reload:"live" taken from next. Other
+// settings only take effect on restart; the paths of those that differ in next are returned
+// so they can be reported.
+func ApplyLive(current, next *Config) (*Config, []string) {
+	applied := *current
+
+	nextFields := Fields(next)
+	var ignored []string
+	for i, f := range Fields(&applied) {
+		n := nextFields[i]
+		if reflect.DeepEqual(f.Value.Interface(), n.Value.Interface()) {
+			continue
+		}
+		if f.Tag.Get("reload") == "live" {
+			f.Value.Set(n.Value)
+			continue
+		}
+		ignored = append(ignored, f.Path)
+	}
+	return &applied, ignored
+}
@@ -0,0 +1,285 @@
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+// redacted replaces secret values in dumps of the configuration
+const redacted = "******"
+
+// SecretProvider resolves secret references of the form ${<scheme>:<ref>}
+type SecretProvider interface {
+	Resolve(ctx context.Context, ref string) (string, error)
+}
+
+// SecretProviderFunc adapts a function to SecretProvider
+type SecretProviderFunc func(ctx context.Context, ref string) (string, error)
+
+// Resolve implements SecretProvider
+func (f SecretProviderFunc) Resolve(ctx context.Context, ref string) (string, error) {
+	return f(ctx, ref)
+}
+
+var (
+	providersMu sync.RWMutex
+	providers   = map[string]SecretProvider{
+		"env":  SecretProviderFunc(resolveEnv),
+		"file": SecretProviderFunc(resolveFile),
+	}
+)
+
+// RegisterSecretProvider makes references with the given scheme resolve through p
+func RegisterSecretProvider(scheme string, p SecretProvider) {
+	providersMu.Lock()
+	defer providersMu.Unlock()
+	providers[scheme] = p
+}
+
+func resolveEnv(_ context.Context, name string) (string, error) {
+	v, ok := os.LookupEnv(name)
+	if !ok {
+		return "", fmt.Errorf("environment variable %s is not set", name)
+	}
+	return v, nil
+}
+
+func resolveFile(_ context.Context, path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+// parseSecretRef splits a reference into scheme and ref: ${env:DB_PASS}, ${vault:path#key} or
+// file:///run/secrets/db. Other values are not references.
+func parseSecretRef(s string) (scheme, ref string, ok bool) {
+	if path, found := strings.CutPrefix(s, "file://"); found {
+		return "file", path, true
+	}
+	if inner, found := strings.CutPrefix(s, "${"); found && strings.HasSuffix(inner, "}") {
+		return strings.Cut(strings.TrimSuffix(inner, "}"), ":")
+	}
+	return "", "", false
+}
+
+// ResolveSecrets replaces secret references with the secrets they point at. Only settings
+// tagged secret:"true" and values under map keys naming a password, secret or token, such as
+// kafka.extra.sasl.password, are resolved; other settings keep values like file:// URLs as
+// they are. The secrets section is resolved first, so the Vault token can itself be a
+// reference, and a Vault provider is registered when secrets.vault.address is set.
+func ResolveSecrets(ctx context.Context, cfg *Config) error {
+	fields := Fields(cfg)
+	var errs []Problem
+
+	resolve := func(path string, secret bool, s string) string {
+		scheme, ref, ok := parseSecretRef(s)
+		if !secret || !ok {
+			return s
+		}
+
+		providersMu.RLock()
+		p, found := providers[scheme]
+		providersMu.RUnlock()
+		if !found {
+			errs = append(errs, Problem{Path: path, Message: fmt.Sprintf("unknown secret provider %q", scheme)})
+			return s
+		}
+		v, err := p.Resolve(ctx, ref)
+		if err != nil {
+			errs = append(errs, Problem{Path: path, Message: fmt.Sprintf("failed to resolve %s secret: %v", scheme, err)})
+			return s
+		}
+		return v
+	}
+
+	for _, f := range fields {
+		if strings.HasPrefix(f.Path, "secrets.") {
+			f.Value.Set(rewriteStrings(f.Value, f.Path, f.Tag.Get("secret") == "true", resolve))
+		}
+	}
+	if v := cfg.Secrets.Vault; v.Address != "" {
+		RegisterSecretProvider("vault", NewVaultProvider(v))
+	}
+	for _, f := range fields {
+		if !strings.HasPrefix(f.Path, "secrets.") {
+			f.Value.Set(rewriteStrings(f.Value, f.Path, f.Tag.Get("secret") == "true", resolve))
+		}
+	}
+
+	if len(errs) > 0 {
+		return &ValidationError{Problems: errs}
+	}
+	return nil
+}
+
+// VaultProvider reads secrets from the KV engine of a Vault-compatible HTTP API. References
+// are <path>#<field>, such as ${vault:secret/data/db#password}; the field defaults to value.
+type VaultProvider struct {
+	address string
+	token   string
+	client  *http.Client
+}
+
+// NewVaultProvider creates a provider for the server in cfg
+func NewVaultProvider(cfg VaultConfig) *VaultProvider {
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	return &VaultProvider{
+		address: strings.TrimRight(cfg.Address, "/"),
+		token:   cfg.Token,
+		client:  &http.Client{Timeout: timeout},
+	}
+}
+
+// Resolve implements SecretProvider
+func (p *VaultProvider) Resolve(ctx context.Context, ref string) (string, error) {
+	path, field, _ := strings.Cut(ref, "#")
+	if field == "" {
+		field = "value"
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.address+"/v1/"+strings.TrimLeft(path, "/"), nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("X-Vault-Token", p.token)
+
+	res, err := p.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("vault returned %s for %s", res.Status, path)
+	}
+
+	// KV version 2 nests the secret under data.data, version 1 under data
+	var body struct {
+		Data map[string]json.RawMessage `json:"data"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+		return "", fmt.Errorf("failed to decode vault response: %w", err)
+	}
+	data := body.Data
+	if nested, ok := data["data"]; ok && len(nested) > 0 && nested[0] == '{' {
+		if err := json.Unmarshal(nested, &data); err != nil {
+			return "", fmt.Errorf("failed to decode vault response: %w", err)
+		}
+	}
+
+	raw, ok := data[field]
+	if !ok {
+		return "", fmt.Errorf("vault secret %s has no field %s", path, field)
+	}
+	var v string
+	if err := json.Unmarshal(raw, &v); err != nil {
+		return "", fmt.Errorf("vault secret %s field %s is not a string", path, field)
+	}
+	return v, nil
+}
+
+// Redacted returns a copy of cfg safe to log or print: settings tagged secret:"true" and
+// values under keys naming a password, secret or token, at any depth of maps and lists, are
+// replaced
+func (c *Config) Redacted() *Config {
+	redact := func(_ string, secret bool, s string) string {
+		if secret && s != "" {
+			return redacted
+		}
+		return s
+	}
+
+	out := *c
+	for _, f := range Fields(&out) {
+		f.Value.Set(rewriteStrings(f.Value, f.Path, f.Tag.Get("secret") == "true", redact))
+	}
+	return &out
+}
+
+// rewriteStrings returns v with every string replaced by fn, descending into maps, lists and
+// structs. Maps and lists are copied rather than changed in place. fn gets the dotted path of
+// the string and whether it is secret: tagged secret:"true" or under a secret-looking key.
+func rewriteStrings(v reflect.Value, path string, secret bool, fn func(path string, secret bool, s string) string) reflect.Value {
+	switch v.Kind() {
+	case reflect.String:
+		return reflect.ValueOf(fn(path, secret, v.String())).Convert(v.Type())
+	case reflect.Interface:
+		if v.IsNil() {
+			return v
+		}
+		out := reflect.New(v.Type()).Elem()
+		switch elem := v.Elem(); elem.Kind() {
+		case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Float32, reflect.Float64:
+			// Unquoted YAML values such as password: 123456 decode as numbers
+			s := fmt.Sprint(elem.Interface())
+			if r := fn(path, secret, s); r != s && v.Type().NumMethod() == 0 {
+				out.Set(reflect.ValueOf(r))
+				return out
+			}
+			return v
+		default:
+			out.Set(rewriteStrings(elem, path, secret, fn))
+		}
+		return out
+	case reflect.Map:
+		if v.IsNil() || v.Type().Key().Kind() != reflect.String {
+			return v
+		}
+		out := reflect.MakeMapWithSize(v.Type(), v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			key := iter.Key().String()
+			out.SetMapIndex(iter.Key(), rewriteStrings(iter.Value(), path+"."+key, secret || secretKey(key), fn))
+		}
+		return out
+	case reflect.Slice:
+		if v.IsNil() || v.Type().Elem().Kind() == reflect.Uint8 {
+			return v
+		}
+		out := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			out.Index(i).Set(rewriteStrings(v.Index(i), fmt.Sprintf("%s[%d]", path, i), secret, fn))
+		}
+		return out
+	case reflect.Struct:
+		out := reflect.New(v.Type()).Elem()
+		out.Set(v)
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			sf := t.Field(i)
+			name := strings.Split(sf.Tag.Get("mapstructure"), ",")[0]
+			if name == "" || name == "-" || !sf.IsExported() {
+				continue
+			}
+			fieldSecret := secret || sf.Tag.Get("secret") == "true" || secretKey(name)
+			out.Field(i).Set(rewriteStrings(v.Field(i), path+"."+name, fieldSecret, fn))
+		}
+		return out
+	default:
+		return v
+	}
+}
+
+// secretKey reports whether a map key names a secret, such as sasl.password
+func secretKey(key string) bool {
+	key = strings.ToLower(key)
+	return strings.Contains(key, "password") || strings.Contains(key, "secret") || strings.Contains(key, "token")
+}
+
+// String formats the configuration with its secrets redacted, so it is safe to log
+func (c *Config) String() string {
+	type plain Config
+	return fmt.Sprintf("%+v", *(*plain)(c.Redacted()))
+}
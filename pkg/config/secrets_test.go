@@ -0,0 +1,230 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseSecretRef(t *testing.T) {
+	tests := []struct {
+		in          string
+		scheme, ref string
+		ok          bool
+	}{
+		{"${env:DB_PASS}", "env", "DB_PASS", true},
+		{"${vault:secret/data/db#password}", "vault", "secret/data/db#password", true},
+		{"file:///run/secrets/db", "file", "/run/secrets/db", true},
+		{"plain", "", "", false},
+		{"${env:DB_PASS", "", "", false},
+		{"prefix ${env:DB_PASS}", "", "", false},
+	}
+	for _, tt := range tests {
+		scheme, ref, ok := parseSecretRef(tt.in)
+		if scheme != tt.scheme || ref != tt.ref || ok != tt.ok {
+			t.Errorf("parseSecretRef(%q) = %q, %q, %v; want %q, %q, %v", tt.in, scheme, ref, ok, tt.scheme, tt.ref, tt.ok)
+		}
+	}
+}
+
+func TestResolveSecretsEnvAndFile(t *testing.T) {
+	t.Setenv("TEST_DB_PASS", "hunter2")
+	file := filepath.Join(t.TempDir(), "redis")
+	if err := os.WriteFile(file, []byte("s3cret\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &Config{}
+	cfg.Database.MySQL.Password = "${env:TEST_DB_PASS}"
+	cfg.Cache.Redis.Password = "file://" + file
+	cfg.Kafka.Extra = map[string]any{
+		"sasl.password": "${env:TEST_DB_PASS}",
+		"sasl":          map[string]any{"password": "file://" + file},
+		"client.id":     "${env:TEST_DB_PASS}",
+	}
+	// Settings that are not secrets keep values that look like references
+	cfg.App.Name = "${app:name}"
+	cfg.Kafka.SchemaRegistry.URL = "file://" + file
+
+	if err := ResolveSecrets(context.Background(), cfg); err != nil {
+		t.Fatalf("ResolveSecrets: %v", err)
+	}
+
+	checks := []struct{ name, got, want string }{
+		{"mysql password", cfg.Database.MySQL.Password, "hunter2"},
+		{"redis password", cfg.Cache.Redis.Password, "s3cret"},
+		{"extra sasl.password", cfg.Kafka.Extra["sasl.password"].(string), "hunter2"},
+		{"extra sasl.password nested", cfg.Kafka.Extra["sasl"].(map[string]any)["password"].(string), "s3cret"},
+		{"extra client.id", cfg.Kafka.Extra["client.id"].(string), "${env:TEST_DB_PASS}"},
+		{"app name", cfg.App.Name, "${app:name}"},
+		{"schema registry url", cfg.Kafka.SchemaRegistry.URL, "file://" + file},
+	}
+	for _, c := range checks {
+		if c.got != c.want {
+			t.Errorf("%s = %q, want %q", c.name, c.got, c.want)
+		}
+	}
+}
+
+func TestResolveSecretsErrors(t *testing.T) {
+	cfg := &Config{}
+	cfg.Database.MySQL.Password = "${env:TEST_UNSET_VARIABLE}"
+	cfg.Cache.Redis.Password = "${nope:x}"
+	cfg.Elasticsearch.APIKey = "file://" + filepath.Join(t.TempDir(), "missing")
+
+	err := ResolveSecrets(context.Background(), cfg)
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("ResolveSecrets = %v, want a *ValidationError", err)
+	}
+
+	paths := map[string]string{}
+	for _, p := range verr.Problems {
+		paths[p.Path] = p.Message
+	}
+	for path, want := range map[string]string{
+		"database.mysql.password": "TEST_UNSET_VARIABLE is not set",
+		"cache.redis.password":    `unknown secret provider "nope"`,
+		"elasticsearch.api_key":   "failed to resolve file secret",
+	} {
+		if !strings.Contains(paths[path], want) {
+			t.Errorf("problem for %s = %q, want it to mention %q", path, paths[path], want)
+		}
+	}
+	if cfg.Database.MySQL.Password != "${env:TEST_UNSET_VARIABLE}" {
+		t.Errorf("unresolved reference replaced with %q", cfg.Database.MySQL.Password)
+	}
+}
+
+// vaultStub serves KV secrets the way Vault does, checking the token
+func vaultStub(t *testing.T, token string) *httptest.Server {
+	secrets := map[string]string{
+		"/v1/secret/data/db": `{"data": {"data": {"password": "kv2-pass", "value": "kv2-value"}, "metadata": {"version": 3}}}`,
+		"/v1/kv/db":          `{"data": {"password": "kv1-pass", "port": 5432}}`,
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != token {
+			http.Error(w, `{"errors": ["permission denied"]}`, http.StatusForbidden)
+			return
+		}
+		body, ok := secrets[r.URL.Path]
+		if !ok {
+			http.Error(w, `{"errors": []}`, http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte(body))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestVaultProvider(t *testing.T) {
+	srv := vaultStub(t, "root")
+	p := NewVaultProvider(VaultConfig{Address: srv.URL + "/", Token: "root"})
+
+	tests := []struct {
+		ref     string
+		want    string
+		wantErr string
+	}{
+		{"secret/data/db#password", "kv2-pass", ""},
+		{"secret/data/db", "kv2-value", ""},
+		{"/kv/db#password", "kv1-pass", ""},
+		{"kv/db#user", "", "has no field user"},
+		{"kv/db#port", "", "is not a string"},
+		{"secret/data/missing#password", "", "404"},
+	}
+	for _, tt := range tests {
+		got, err := p.Resolve(context.Background(), tt.ref)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Resolve(%q) error = %v, want it to mention %q", tt.ref, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("Resolve(%q) = %q, %v; want %q", tt.ref, got, err, tt.want)
+		}
+	}
+
+	denied := NewVaultProvider(VaultConfig{Address: srv.URL, Token: "wrong"})
+	if _, err := denied.Resolve(context.Background(), "kv/db#password"); err == nil || !strings.Contains(err.Error(), "403") {
+		t.Errorf("Resolve with a wrong token = %v, want a 403 error", err)
+	}
+}
+
+func TestResolveSecretsVault(t *testing.T) {
+	srv := vaultStub(t, "root")
+	t.Setenv("TEST_VAULT_TOKEN", "root")
+
+	cfg := &Config{}
+	cfg.Secrets.Vault = VaultConfig{Address: srv.URL, Token: "${env:TEST_VAULT_TOKEN}"}
+	cfg.Database.PostgreSQL.Password = "${vault:secret/data/db#password}"
+	cfg.Kafka.Extra = map[string]any{"ssl.key.password": "${vault:kv/db#password}"}
+
+	if err := ResolveSecrets(context.Background(), cfg); err != nil {
+		t.Fatalf("ResolveSecrets: %v", err)
+	}
+	if cfg.Secrets.Vault.Token != "root" {
+		t.Errorf("vault token = %q, want it resolved before use", cfg.Secrets.Vault.Token)
+	}
+	if cfg.Database.PostgreSQL.Password != "kv2-pass" {
+		t.Errorf("postgres password = %q, want kv2-pass", cfg.Database.PostgreSQL.Password)
+	}
+	if got := cfg.Kafka.Extra["ssl.key.password"]; got != "kv1-pass" {
+		t.Errorf("extra ssl.key.password = %v, want kv1-pass", got)
+	}
+}
+
+func TestRedacted(t *testing.T) {
+	cfg := &Config{}
+	cfg.App.Name = "yourapp"
+	cfg.Database.MySQL.Password = "hunter2"
+	cfg.Elasticsearch.APIKey = "key"
+	cfg.Cache.Redis.Password = ""
+	cfg.Kafka.Extra = map[string]any{
+		"sasl.password": "hunter3",
+		"sasl":          map[string]any{"password": "hunter4", "mechanism": "PLAIN"},
+		"pin":           map[string]any{"token": 123456},
+		"list":          []any{map[string]any{"secret": "hunter5"}},
+		"client.id":     "app",
+	}
+
+	out := cfg.Redacted()
+
+	checks := []struct {
+		name string
+		got  any
+		want any
+	}{
+		{"mysql password", out.Database.MySQL.Password, redacted},
+		{"api key", out.Elasticsearch.APIKey, redacted},
+		{"empty redis password", out.Cache.Redis.Password, ""},
+		{"app name", out.App.Name, "yourapp"},
+		{"extra sasl.password", out.Kafka.Extra["sasl.password"], redacted},
+		{"extra nested password", out.Kafka.Extra["sasl"].(map[string]any)["password"], redacted},
+		{"extra nested mechanism", out.Kafka.Extra["sasl"].(map[string]any)["mechanism"], "PLAIN"},
+		{"extra numeric token", out.Kafka.Extra["pin"].(map[string]any)["token"], redacted},
+		{"extra list secret", out.Kafka.Extra["list"].([]any)[0].(map[string]any)["secret"], redacted},
+		{"extra client.id", out.Kafka.Extra["client.id"], "app"},
+		{"original password", cfg.Database.MySQL.Password, "hunter2"},
+		{"original nested password", cfg.Kafka.Extra["sasl"].(map[string]any)["password"], "hunter4"},
+	}
+	for _, c := range checks {
+		if c.got != c.want {
+			t.Errorf("%s = %v, want %v", c.name, c.got, c.want)
+		}
+	}
+
+	s := cfg.String()
+	for _, secret := range []string{"hunter2", "hunter3", "hunter4", "hunter5", "123456"} {
+		if strings.Contains(s, secret) {
+			t.Errorf("String() leaks %s", secret)
+		}
+	}
+}
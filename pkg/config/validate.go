@@ -0,0 +1,317 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// Problem is one invalid setting
+type Problem struct {
+	Path    string
+	Message string
+}
+
+// ValidationError lists every problem found in a configuration
+type ValidationError struct {
+	Problems []Problem
+}
+
+func (e *ValidationError) Error() string {
+	var b strings.Builder
+	b.WriteString("invalid configuration:")
+	for _, p := range e.Problems {
+		fmt.Fprintf(&b, "\n  %s: %s", p.Path, p.Message)
+	}
+	return b.String()
+}
+
+// switchable lists the sections turned off by their enabled setting. The rules of a disabled
+// section are not checked, so the values it is left with cannot fail the configuration.
+var switchable = []string{"database.mysql", "database.postgres", "cache.redis", "elasticsearch", "kafka"}
+
+// Validate checks every setting against the rule in its validate tag, then the rules that
+// relate settings to each other. Settings of disabled sections are skipped. It returns a
+// *ValidationError listing all problems.
+//
+// Tag rules, separated by commas: required (not empty), port (1 to 65535), min=N and max=N
+// (numbers and durations) and oneof=a b c (strings, compared case-insensitively). The fields
+// of list entries such as kafka.topics are checked too, with paths like kafka.topics[0].name.
+func (c *Config) Validate() error {
+	fields := Fields(c)
+	disabled := disabledSections(fields)
+
+	var problems []Problem
+	for _, f := range withElements(fields) {
+		if hasAnyPrefix(f.Path, disabled) {
+			continue
+		}
+		for _, rule := range strings.Split(f.Tag.Get("validate"), ",") {
+			if rule == "" {
+				continue
+			}
+			if msg := checkRule(rule, f.Value); msg != "" {
+				problems = append(problems, Problem{Path: f.Path, Message: msg})
+			}
+		}
+	}
+	problems = append(problems, c.crossChecks(disabled)...)
+
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	return nil
+}
+
+// checkRule returns why v breaks rule, or "" when it does not
+func checkRule(rule string, v reflect.Value) string {
+	name, arg, _ := strings.Cut(rule, "=")
+	switch name {
+	case "required":
+		if v.IsZero() {
+			return "is required"
+		}
+	case "port":
+		if n := v.Int(); n < 1 || n > 65535 {
+			return fmt.Sprintf("must be a port between 1 and 65535, got %d", n)
+		}
+	case "min", "max":
+		limit, err := strconv.ParseInt(arg, 10, 64)
+		if err != nil {
+			return fmt.Sprintf("invalid rule %q", rule)
+		}
+		n := v.Int()
+		if name == "min" && n < limit || name == "max" && n > limit {
+			if name == "min" && limit == 0 {
+				return fmt.Sprintf("must not be negative, got %v", v.Interface())
+			}
+			return fmt.Sprintf("must be at %s %d, got %v", map[string]string{"min": "least", "max": "most"}[name], limit, v.Interface())
+		}
+	case "oneof":
+		allowed := strings.Fields(arg)
+		s := v.String()
+		for _, a := range allowed {
+			if strings.EqualFold(s, a) {
+				return ""
+			}
+		}
+		return fmt.Sprintf("must be one of %s, got %q", strings.Join(allowed, ", "), s)
+	default:
+		return fmt.Sprintf("unknown validation rule %q", rule)
+	}
+	return ""
+}
+
+// withElements returns fields followed by the fields of the entries of struct lists, whose
+// paths index the list
+func withElements(fields []Field) []Field {
+	out := fields
+	for _, f := range fields {
+		if f.Value.Kind() != reflect.Slice || f.Value.Type().Elem().Kind() != reflect.Struct {
+			continue
+		}
+		for i := 0; i < f.Value.Len(); i++ {
+			walkFields(fmt.Sprintf("%s[%d]", f.Path, i), f.Value.Index(i), func(ef Field) {
+				out = append(out, ef)
+			})
+		}
+	}
+	return out
+}
+
+// disabledSections returns the switchable sections whose enabled setting is off, as path
+// prefixes ending in a dot
+func disabledSections(fields []Field) []string {
+	enabled := make(map[string]bool, len(switchable))
+	for _, f := range fields {
+		if section, ok := strings.CutSuffix(f.Path, ".enabled"); ok && f.Value.Kind() == reflect.Bool {
+			enabled[section] = f.Value.Bool()
+		}
+	}
+
+	var disabled []string
+	for _, section := range switchable {
+		if !enabled[section] {
+			disabled = append(disabled, section+".")
+		}
+	}
+	return disabled
+}
+
+// normalize rewrites oneof settings given in another case to the spelling of their rule, so
+// code comparing them sees one value
+func normalize(c *Config) {
+	for _, f := range withElements(Fields(c)) {
+		for _, rule := range strings.Split(f.Tag.Get("validate"), ",") {
+			allowed, ok := strings.CutPrefix(rule, "oneof=")
+			if !ok {
+				continue
+			}
+			for _, a := range strings.Fields(allowed) {
+				if f.Value.String() != a && strings.EqualFold(f.Value.String(), a) {
+					f.Value.SetString(a)
+				}
+			}
+		}
+	}
+}
+
+// crossChecks applies the rules that involve several settings, skipping the disabled sections
+func (c *Config) crossChecks(disabled []string) []Problem {
+	var problems []Problem
+	add := func(path, format string, args ...any) {
+		if !hasAnyPrefix(path, disabled) {
+			problems = append(problems, Problem{Path: path, Message: fmt.Sprintf(format, args...)})
+		}
+	}
+
+	if db := c.Database.MySQL; db.MaxOpenConns > 0 && db.MaxIdleConns > db.MaxOpenConns {
+		add("database.mysql.max_idle_conns", "must not exceed max_open_conns (%d > %d)", db.MaxIdleConns, db.MaxOpenConns)
+	}
+	if db := c.Database.PostgreSQL; db.MaxOpenConns > 0 && db.MaxIdleConns > db.MaxOpenConns {
+		add("database.postgres.max_idle_conns", "must not exceed max_open_conns (%d > %d)", db.MaxIdleConns, db.MaxOpenConns)
+	}
+	if r := c.Cache.Redis; r.PoolSize > 0 && r.MinIdleConns > r.PoolSize {
+		add("cache.redis.min_idle_conns", "must not exceed pool_size (%d > %d)", r.MinIdleConns, r.PoolSize)
+	}
+
+	es := c.Elasticsearch
+	if len(es.Addresses) > 0 && es.CloudID != "" {
+		add("elasticsearch.cloud_id", "cannot be combined with addresses")
+	}
+	if (es.TLS.CertFile == "") != (es.TLS.KeyFile == "") {
+		add("elasticsearch.tls", "cert_file and key_file must be set together")
+	}
+	if (c.Kafka.SSL.CertFile == "") != (c.Kafka.SSL.KeyFile == "") {
+		add("kafka.ssl", "cert_file and key_file must be set together")
+	}
+	if r := c.Kafka.Retry; r.MaxBackoff > 0 && r.Backoff > r.MaxBackoff {
+		add("kafka.retry.backoff", "must not exceed max_backoff (%s > %s)", r.Backoff, r.MaxBackoff)
+	}
+
+	if c.Outbox.Enabled {
+		if c.Outbox.Database == "mysql" && !c.Database.MySQL.Enabled ||
+			c.Outbox.Database == "postgres" && !c.Database.PostgreSQL.Enabled {
+			add("outbox.database", "%s is not enabled", c.Outbox.Database)
+		}
+		if !c.Kafka.Enabled {
+			add("outbox.enabled", "requires kafka.enabled")
+		}
+	}
+
+	if c.Logging.Output == "file" && c.Logging.FilePath == "" {
+		add("logging.file_path", "is required when output is file")
+	}
+	return problems
+}
+
+// unknownKeys reports the keys of a config file that match no setting, such as typos
+func unknownKeys(file string) ([]Problem, error) {
+	v := viper.New()
+	v.SetConfigFile(file)
+	if err := v.ReadInConfig(); err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	known := make(map[string]bool)
+	for old := range deprecatedKeys {
+		known[old] = true
+	}
+	var maps []string
+	for _, f := range Fields(&Config{}) {
+		known[f.Path] = true
+		if f.Value.Kind() == reflect.Map {
+			maps = append(maps, f.Path+".")
+		}
+	}
+
+	var problems []Problem
+	keys := v.AllKeys()
+	sort.Strings(keys)
+	for _, key := range keys {
+		if known[key] || hasAnyPrefix(key, maps) {
+			continue
+		}
+		msg := "unknown key in " + file
+		if s := suggest(key, known); s != "" {
+			msg += ", did you mean " + s + "?"
+		}
+		problems = append(problems, Problem{Path: key, Message: msg})
+	}
+	return problems, nil
+}
+
+func hasAnyPrefix(s string, prefixes []string) bool {
+	for _, p := range prefixes {
+		if strings.HasPrefix(s, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// suggest returns the known key closest to an unknown one in the same section, if it is
+// close enough to be a typo
+func suggest(key string, known map[string]bool) string {
+	section, name := "", key
+	if i := strings.LastIndex(key, "."); i >= 0 {
+		section, name = key[:i+1], key[i+1:]
+	}
+
+	best, bestDist := "", 4
+	for k := range known {
+		rest, ok := strings.CutPrefix(k, section)
+		if _, deprecated := deprecatedKeys[k]; !ok || deprecated || strings.Contains(rest, ".") {
+			continue
+		}
+		if d := editDistance(name, rest); d < bestDist || d == bestDist && rest < best {
+			best, bestDist = rest, d
+		}
+	}
+	return best
+}
+
+// editDistance is the Levenshtein distance between a and b
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
+
+// check validates cfg and the keys of the config files it was read from
+func check(cfg *Config, files ...string) error {
+	var problems []Problem
+	for _, file := range files {
+		unknown, err := unknownKeys(file)
+		if err != nil {
+			return err
+		}
+		problems = append(problems, unknown...)
+	}
+
+	if err := cfg.Validate(); err != nil {
+		problems = append(problems, err.(*ValidationError).Problems...)
+	}
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	return nil
+}
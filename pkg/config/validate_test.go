@@ -0,0 +1,39 @@
+package config
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestValidateKafkaTopics(t *testing.T) {
+	cfg := &Config{}
+	cfg.Kafka.Enabled = true
+	cfg.Kafka.Topics = []KafkaTopicConfig{
+		{Name: "orders", Partitions: 6, ReplicationFactor: 1},
+		{Partitions: 0, ReplicationFactor: -1},
+	}
+
+	got := map[string]string{}
+	var verr *ValidationError
+	if err := cfg.Validate(); errors.As(err, &verr) {
+		for _, p := range verr.Problems {
+			got[p.Path] = p.Message
+		}
+	}
+	want := map[string]string{
+		"kafka.topics[1].name":               "is required",
+		"kafka.topics[1].partitions":         "must be at least 1, got 0",
+		"kafka.topics[1].replication_factor": "must be at least 1, got -1",
+	}
+	for path, msg := range want {
+		if got[path] != msg {
+			t.Errorf("problem for %s = %q, want %q", path, got[path], msg)
+		}
+	}
+	for path := range got {
+		if _, ok := want[path]; !ok && strings.HasPrefix(path, "kafka.topics") {
+			t.Errorf("unexpected problem for %s: %s", path, got[path])
+		}
+	}
+}
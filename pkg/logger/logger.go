@@ -1,6 +1,7 @@
 package logger
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
 
@@ -14,6 +15,7 @@ import (
 var (
 	logger *zap.Logger
 	sugar  *zap.SugaredLogger
+	level  = zap.NewAtomicLevel()
 )
 
 // Init initializes the logger
@@ -32,6 +34,8 @@ func initDefaultLogger() error {
 	config := zap.NewDevelopmentConfig()
 	config.EncoderConfig.TimeKey = "timestamp"
 	config.EncoderConfig.EncodeTime = zapcore.ISO8601TimeEncoder
+	config.Level = level
+	level.SetLevel(zapcore.DebugLevel)
 
 	var err error
 	logger, err = config.Build()
@@ -46,9 +50,8 @@ func initDefaultLogger() error {
 // initLoggerWithConfig initializes logger with application configuration
 func initLoggerWithConfig(cfg *appconfig.Config) error {
 	// Set log level
-	level, err := parseLogLevel(cfg.Logging.Level)
-	if err != nil {
-		level = zapcore.InfoLevel
+	if err := SetLevel(cfg.Logging.Level); err != nil {
+		level.SetLevel(zapcore.InfoLevel)
 	}
 
 	// Configure encoder
@@ -133,8 +136,18 @@ func parseLogLevel(level string) (zapcore.Level, error) {
 	case "panic":
 		return zapcore.PanicLevel, nil
 	default:
-		return zapcore.InfoLevel, nil
+		return zapcore.InfoLevel, fmt.Errorf("unknown log level %q", level)
+	}
+}
+
+// SetLevel changes the minimum level of the logger while it runs
+func SetLevel(name string) error {
+	l, err := parseLogLevel(name)
+	if err != nil {
+		return err
 	}
+	level.SetLevel(l)
+	return nil
 }
 
 // GetLogger returns the logger instance
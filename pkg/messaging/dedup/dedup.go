@@ -0,0 +1,115 @@
+package dedup
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"yourapp/pkg/logger"
+	"yourapp/pkg/messaging/kafka"
+
+	ckafka "github.com/confluentinc/confluent-kafka-go/kafka"
+)
+
+// Store records the ids of processed messages
+type Store interface {
+	// Seen reports whether id has been processed within scope
+	Seen(ctx context.Context, scope, id string) (bool, error)
+	// Mark records id as processed within scope for ttl
+	Mark(ctx context.Context, scope, id string, ttl time.Duration) error
+}
+
+// IDExtractor returns the id that identifies a message across redeliveries
+type IDExtractor func(*ckafka.Message) (string, error)
+
+// Options configures the dedup middleware
+type Options struct {
+	// Scope separates ids of different consumers, usually the consumer group
+	Scope string
+	// TTL is how long a processed id is remembered
+	TTL time.Duration
+	// Extractor returns the message id
+	Extractor IDExtractor
+	// Timeout bounds each store operation
+	Timeout time.Duration
+}
+
+// DefaultOptions returns options keyed by the event-id header, scoped to scope
+func DefaultOptions(scope string) Options {
+	return Options{
+		Scope:     scope,
+		TTL:       24 * time.Hour,
+		Extractor: HeaderID(kafka.HeaderEventID),
+		Timeout:   5 * time.Second,
+	}
+}
+
+// HeaderID extracts the message id from a header, falling back to the message's
+// topic, partition and offset when the header is missing
+func HeaderID(name string) IDExtractor {
+	return func(msg *ckafka.Message) (string, error) {
+		for i := len(msg.Headers) - 1; i >= 0; i-- {
+			if msg.Headers[i].Key == name && len(msg.Headers[i].Value) > 0 {
+				return string(msg.Headers[i].Value), nil
+			}
+		}
+		return OffsetID(msg)
+	}
+}
+
+// OffsetID identifies a message by its topic, partition and offset
+func OffsetID(msg *ckafka.Message) (string, error) {
+	return fmt.Sprintf("%s/%d/%d", *msg.TopicPartition.Topic, msg.TopicPartition.Partition, msg.TopicPartition.Offset), nil
+}
+
+// Middleware skips messages whose id store has already seen and marks the id once next
+// succeeds. A duplicate delivered while the first copy is still being handled can slip
+// through; use TxMiddleware when the handler writes to SQL and needs exactly-once effects.
+func Middleware(store Store, opts Options, next kafka.Handler) kafka.Handler {
+	opts = withDefaults(opts)
+
+	return func(msg *ckafka.Message) error {
+		id, err := opts.Extractor(msg)
+		if err != nil {
+			return fmt.Errorf("failed to extract message id: %w", err)
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), opts.Timeout)
+		seen, err := store.Seen(ctx, opts.Scope, id)
+		cancel()
+		if err != nil {
+			return fmt.Errorf("failed to check message %s: %w", id, err)
+		}
+		if seen {
+			logger.Debugf("Skipping duplicate message %s from %s", id, msg.TopicPartition)
+			return nil
+		}
+
+		if err := next(msg); err != nil {
+			return err
+		}
+
+		ctx, cancel = context.WithTimeout(context.Background(), opts.Timeout)
+		defer cancel()
+		if err := store.Mark(ctx, opts.Scope, id, opts.TTL); err != nil {
+			// The message was handled; a failed mark only risks reprocessing a redelivery
+			logger.Errorf("Failed to mark message %s as processed: %v", id, err)
+		}
+		return nil
+	}
+}
+
+// withDefaults fills unset options
+func withDefaults(opts Options) Options {
+	def := DefaultOptions(opts.Scope)
+	if opts.TTL <= 0 {
+		opts.TTL = def.TTL
+	}
+	if opts.Extractor == nil {
+		opts.Extractor = def.Extractor
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = def.Timeout
+	}
+	return opts
+}
@@ -0,0 +1,40 @@
+package dedup
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// RedisStore keeps processed ids as expiring Redis keys
+type RedisStore struct {
+	client func() *redis.Client
+	prefix string
+}
+
+// NewRedisStore creates a Redis backed store; keys are named <prefix><scope>:<id>. client is
+// called for every command, such as redisx.GetClient, so the store follows a client replaced
+// when the pool settings change.
+func NewRedisStore(client func() *redis.Client, prefix string) *RedisStore {
+	if prefix == "" {
+		prefix = "dedup:"
+	}
+	return &RedisStore{client: client, prefix: prefix}
+}
+
+// Seen reports whether id has been processed within scope
+func (s *RedisStore) Seen(ctx context.Context, scope, id string) (bool, error) {
+	n, err := s.client().Exists(ctx, s.key(scope, id)).Result()
+	return n > 0, err
+}
+
+// Mark records id as processed within scope for ttl
+func (s *RedisStore) Mark(ctx context.Context, scope, id string, ttl time.Duration) error {
+	return s.client().Set(ctx, s.key(scope, id), 1, ttl).Err()
+}
+
+// key returns the Redis key for an id
+func (s *RedisStore) key(scope, id string) string {
+	return s.prefix + scope + ":" + id
+}
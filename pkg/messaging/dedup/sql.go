@@ -0,0 +1,113 @@
+package dedup
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"yourapp/pkg/logger"
+	"yourapp/pkg/messaging/kafka"
+
+	ckafka "github.com/confluentinc/confluent-kafka-go/kafka"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ProcessedMessage is a row in the dedup table
+type ProcessedMessage struct {
+	Scope     string    `gorm:"primaryKey;size:191"`
+	MessageID string    `gorm:"primaryKey;size:191"`
+	ExpiresAt time.Time `gorm:"index"`
+	CreatedAt time.Time
+}
+
+// TableName returns the dedup table name
+func (ProcessedMessage) TableName() string {
+	return "processed_messages"
+}
+
+// SQLStore keeps processed ids in a table, in MySQL or PostgreSQL
+type SQLStore struct {
+	db *gorm.DB
+}
+
+// NewSQLStore creates a SQL backed store, creating its table if needed
+func NewSQLStore(db *gorm.DB) (*SQLStore, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database not initialized")
+	}
+	if err := db.AutoMigrate(&ProcessedMessage{}); err != nil {
+		return nil, fmt.Errorf("failed to migrate dedup table: %w", err)
+	}
+	return &SQLStore{db: db}, nil
+}
+
+// Seen reports whether id has been processed within scope
+func (s *SQLStore) Seen(ctx context.Context, scope, id string) (bool, error) {
+	var count int64
+	err := s.db.WithContext(ctx).Model(&ProcessedMessage{}).
+		Where("scope = ? AND message_id = ? AND expires_at > ?", scope, id, time.Now()).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// Mark records id as processed within scope for ttl
+func (s *SQLStore) Mark(ctx context.Context, scope, id string, ttl time.Duration) error {
+	_, err := s.claim(s.db.WithContext(ctx), scope, id, ttl)
+	return err
+}
+
+// claim inserts the id using tx and reports false when it was already present.
+// An expired row for the same id is replaced.
+func (s *SQLStore) claim(tx *gorm.DB, scope, id string, ttl time.Duration) (bool, error) {
+	now := time.Now()
+	if err := tx.Where("scope = ? AND message_id = ? AND expires_at <= ?", scope, id, now).
+		Delete(&ProcessedMessage{}).Error; err != nil {
+		return false, fmt.Errorf("failed to expire message %s: %w", id, err)
+	}
+
+	res := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&ProcessedMessage{
+		Scope:     scope,
+		MessageID: id,
+		ExpiresAt: now.Add(ttl),
+	})
+	if res.Error != nil {
+		return false, fmt.Errorf("failed to mark message %s: %w", id, res.Error)
+	}
+	return res.RowsAffected > 0, nil
+}
+
+// Purge deletes expired ids
+func (s *SQLStore) Purge(ctx context.Context) (int64, error) {
+	res := s.db.WithContext(ctx).Where("expires_at <= ?", time.Now()).Delete(&ProcessedMessage{})
+	return res.RowsAffected, res.Error
+}
+
+// TxHandler processes a message inside a database transaction
+type TxHandler func(tx *gorm.DB, msg *ckafka.Message) error
+
+// TxMiddleware runs next in a transaction that also records the message id, so the
+// handler's writes and the dedup mark commit or roll back together. A concurrent
+// duplicate blocks on the id's row and is skipped once the first copy commits.
+func TxMiddleware(store *SQLStore, opts Options, next TxHandler) kafka.Handler {
+	opts = withDefaults(opts)
+
+	return func(msg *ckafka.Message) error {
+		id, err := opts.Extractor(msg)
+		if err != nil {
+			return fmt.Errorf("failed to extract message id: %w", err)
+		}
+
+		return store.db.Transaction(func(tx *gorm.DB) error {
+			claimed, err := store.claim(tx, opts.Scope, id, opts.TTL)
+			if err != nil {
+				return err
+			}
+			if !claimed {
+				logger.Debugf("Skipping duplicate message %s from %s", id, msg.TopicPartition)
+				return nil
+			}
+			return next(tx, msg)
+		})
+	}
+}
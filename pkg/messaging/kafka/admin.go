@@ -0,0 +1,325 @@
+package kafka
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"yourapp/pkg/config"
+	"yourapp/pkg/logger"
+
+	ckafka "github.com/confluentinc/confluent-kafka-go/kafka"
+)
+
+// adminTimeout bounds metadata requests made by the admin API
+const adminTimeout = 10 * time.Second
+
+var (
+	admin *ckafka.AdminClient
+)
+
+// TopicInfo describes a topic and its partitions
+type TopicInfo struct {
+	Name       string
+	Partitions []PartitionInfo
+}
+
+// PartitionInfo describes the replicas of a partition
+type PartitionInfo struct {
+	ID       int32
+	Leader   int32
+	Replicas []int32
+	ISR      []int32
+}
+
+// initAdmin creates the admin client on top of the producer's connection
+func initAdmin() error {
+	var err error
+	admin, err = ckafka.NewAdminClientFromProducer(producer)
+	if err != nil {
+		return fmt.Errorf("failed to create Kafka admin client: %w", err)
+	}
+	return nil
+}
+
+// GetAdmin returns the Kafka admin client
+func GetAdmin() *ckafka.AdminClient {
+	return admin
+}
+
+// CreateTopic creates a new topic
+func CreateTopic(ctx context.Context, topicName string, numPartitions int32, replicationFactor int16) error {
+	return CreateTopics(ctx, ckafka.TopicSpecification{
+		Topic:             topicName,
+		NumPartitions:     int(numPartitions),
+		ReplicationFactor: int(replicationFactor),
+	})
+}
+
+// CreateTopics creates the given topics. Topics that already exist are not an error.
+func CreateTopics(ctx context.Context, specs ...ckafka.TopicSpecification) error {
+	if admin == nil {
+		return fmt.Errorf("Kafka admin client not initialized")
+	}
+
+	results, err := admin.CreateTopics(ctx, specs, ckafka.SetAdminOperationTimeout(adminTimeout))
+	if err != nil {
+		return fmt.Errorf("failed to create topics: %w", err)
+	}
+
+	for _, r := range results {
+		switch r.Error.Code() {
+		case ckafka.ErrNoError:
+			logger.Infof("Kafka topic %s created", r.Topic)
+		case ckafka.ErrTopicAlreadyExists:
+		default:
+			return fmt.Errorf("failed to create topic %s: %w", r.Topic, r.Error)
+		}
+	}
+
+	return nil
+}
+
+// DeleteTopics deletes the given topics. Topics that do not exist are not an error.
+func DeleteTopics(ctx context.Context, topics ...string) error {
+	if admin == nil {
+		return fmt.Errorf("Kafka admin client not initialized")
+	}
+
+	results, err := admin.DeleteTopics(ctx, topics, ckafka.SetAdminOperationTimeout(adminTimeout))
+	if err != nil {
+		return fmt.Errorf("failed to delete topics: %w", err)
+	}
+
+	for _, r := range results {
+		switch r.Error.Code() {
+		case ckafka.ErrNoError:
+			logger.Infof("Kafka topic %s deleted", r.Topic)
+		case ckafka.ErrUnknownTopicOrPart:
+		default:
+			return fmt.Errorf("failed to delete topic %s: %w", r.Topic, r.Error)
+		}
+	}
+
+	return nil
+}
+
+// ListTopics returns all topics in the cluster with their partitions, sorted by name
+func ListTopics(ctx context.Context) ([]TopicInfo, error) {
+	if admin == nil {
+		return nil, fmt.Errorf("Kafka admin client not initialized")
+	}
+
+	md, err := admin.GetMetadata(nil, true, int(adminTimeout/time.Millisecond))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get metadata: %w", err)
+	}
+
+	topics := make([]TopicInfo, 0, len(md.Topics))
+	for _, t := range md.Topics {
+		if t.Error.Code() != ckafka.ErrNoError {
+			continue
+		}
+		topics = append(topics, topicInfo(t))
+	}
+	sort.Slice(topics, func(i, j int) bool { return topics[i].Name < topics[j].Name })
+
+	return topics, nil
+}
+
+// DescribeTopic returns a topic with its partitions, or nil when it does not exist
+func DescribeTopic(ctx context.Context, topic string) (*TopicInfo, error) {
+	if admin == nil {
+		return nil, fmt.Errorf("Kafka admin client not initialized")
+	}
+
+	// Request metadata of all topics: asking the broker for a missing topic by name lets it
+	// auto-create the topic with broker defaults, so EnsureTopics would never create it
+	md, err := admin.GetMetadata(nil, true, int(adminTimeout/time.Millisecond))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get metadata for topic %s: %w", topic, err)
+	}
+
+	t, ok := md.Topics[topic]
+	if !ok || t.Error.Code() == ckafka.ErrUnknownTopicOrPart {
+		return nil, nil
+	}
+	if t.Error.Code() != ckafka.ErrNoError {
+		return nil, fmt.Errorf("failed to describe topic %s: %w", topic, t.Error)
+	}
+
+	info := topicInfo(t)
+	return &info, nil
+}
+
+// topicInfo converts topic metadata
+func topicInfo(t ckafka.TopicMetadata) TopicInfo {
+	info := TopicInfo{Name: t.Topic, Partitions: make([]PartitionInfo, 0, len(t.Partitions))}
+	for _, p := range t.Partitions {
+		info.Partitions = append(info.Partitions, PartitionInfo{
+			ID:       p.ID,
+			Leader:   p.Leader,
+			Replicas: p.Replicas,
+			ISR:      p.Isrs,
+		})
+	}
+	sort.Slice(info.Partitions, func(i, j int) bool { return info.Partitions[i].ID < info.Partitions[j].ID })
+	return info
+}
+
+// TopicConfig returns the configuration entries explicitly set on a topic
+func TopicConfig(ctx context.Context, topic string) (map[string]string, error) {
+	if admin == nil {
+		return nil, fmt.Errorf("Kafka admin client not initialized")
+	}
+
+	results, err := admin.DescribeConfigs(ctx, []ckafka.ConfigResource{{Type: ckafka.ResourceTopic, Name: topic}})
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe config of topic %s: %w", topic, err)
+	}
+
+	values := make(map[string]string)
+	for _, r := range results {
+		if r.Error.Code() != ckafka.ErrNoError {
+			return nil, fmt.Errorf("failed to describe config of topic %s: %w", topic, r.Error)
+		}
+		for name, entry := range r.Config {
+			if entry.Source == ckafka.ConfigSourceDynamicTopic {
+				values[name] = entry.Value
+			}
+		}
+	}
+
+	return values, nil
+}
+
+// AlterTopicConfig sets configuration entries on a topic, keeping other entries already set on it
+func AlterTopicConfig(ctx context.Context, topic string, values map[string]string) error {
+	// AlterConfigs replaces the whole topic config, so merge with what is set today
+	current, err := TopicConfig(ctx, topic)
+	if err != nil {
+		return err
+	}
+	for k, v := range values {
+		current[k] = v
+	}
+
+	results, err := admin.AlterConfigs(ctx, []ckafka.ConfigResource{{
+		Type:   ckafka.ResourceTopic,
+		Name:   topic,
+		Config: ckafka.StringMapToConfigEntries(current, ckafka.AlterOperationSet),
+	}}, ckafka.SetAdminRequestTimeout(adminTimeout))
+	if err != nil {
+		return fmt.Errorf("failed to alter config of topic %s: %w", topic, err)
+	}
+
+	for _, r := range results {
+		if r.Error.Code() != ckafka.ErrNoError {
+			return fmt.Errorf("failed to alter config of topic %s: %w", topic, r.Error)
+		}
+	}
+
+	logger.Infof("Kafka topic %s config updated: %v", topic, values)
+	return nil
+}
+
+// AddPartitions increases the partition count of a topic to total
+func AddPartitions(ctx context.Context, topic string, total int) error {
+	if admin == nil {
+		return fmt.Errorf("Kafka admin client not initialized")
+	}
+
+	results, err := admin.CreatePartitions(ctx, []ckafka.PartitionsSpecification{{Topic: topic, IncreaseTo: total}},
+		ckafka.SetAdminOperationTimeout(adminTimeout))
+	if err != nil {
+		return fmt.Errorf("failed to add partitions to topic %s: %w", topic, err)
+	}
+
+	for _, r := range results {
+		if r.Error.Code() != ckafka.ErrNoError {
+			return fmt.Errorf("failed to add partitions to topic %s: %w", topic, r.Error)
+		}
+	}
+
+	logger.Infof("Kafka topic %s now has %d partitions", topic, total)
+	return nil
+}
+
+// EnsureTopics makes sure every configured topic exists with at least the configured number
+// of partitions and the configured topic config. Partitions are never removed and the
+// replication factor of an existing topic is left untouched.
+func EnsureTopics(ctx context.Context, topics []config.KafkaTopicConfig) error {
+	for _, t := range expandTopics(topics) {
+		if err := ensureTopic(ctx, t); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// expandTopics adds the retry and dead-letter topics of topics that ask for them
+func expandTopics(topics []config.KafkaTopicConfig) []config.KafkaTopicConfig {
+	var out []config.KafkaTopicConfig
+	for _, t := range topics {
+		out = append(out, t)
+		if !t.RetryTopics || retryPolicy == nil {
+			continue
+		}
+		for _, name := range append(retryPolicy.RetryTopics(t.Name), retryPolicy.DLQTopic(t.Name)) {
+			derived := t
+			derived.Name = name
+			derived.RetryTopics = false
+			out = append(out, derived)
+		}
+	}
+	return out
+}
+
+// ensureTopic brings a single topic in line with its configuration
+func ensureTopic(ctx context.Context, t config.KafkaTopicConfig) error {
+	info, err := DescribeTopic(ctx, t.Name)
+	if err != nil {
+		return err
+	}
+
+	if info == nil {
+		return CreateTopics(ctx, ckafka.TopicSpecification{
+			Topic:             t.Name,
+			NumPartitions:     t.Partitions,
+			ReplicationFactor: t.ReplicationFactor,
+			Config:            t.Config,
+		})
+	}
+
+	if n := len(info.Partitions); t.Partitions > n {
+		if err := AddPartitions(ctx, t.Name, t.Partitions); err != nil {
+			return err
+		}
+	} else if t.Partitions < n {
+		logger.Warnf("Kafka topic %s has %d partitions, more than the configured %d", t.Name, n, t.Partitions)
+	}
+
+	if len(info.Partitions) > 0 && t.ReplicationFactor > 0 && len(info.Partitions[0].Replicas) != t.ReplicationFactor {
+		logger.Warnf("Kafka topic %s has replication factor %d, configured %d; replication factor is not changed automatically",
+			t.Name, len(info.Partitions[0].Replicas), t.ReplicationFactor)
+	}
+
+	if len(t.Config) == 0 {
+		return nil
+	}
+	current, err := TopicConfig(ctx, t.Name)
+	if err != nil {
+		return err
+	}
+	changed := make(map[string]string)
+	for k, v := range t.Config {
+		if current[k] != v {
+			changed[k] = v
+		}
+	}
+	if len(changed) == 0 {
+		return nil
+	}
+	return AlterTopicConfig(ctx, t.Name, changed)
+}
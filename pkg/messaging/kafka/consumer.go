@@ -0,0 +1,505 @@
+package kafka
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+	"sync"
+	"time"
+
+	"yourapp/pkg/logger"
+
+	ckafka "github.com/confluentinc/confluent-kafka-go/kafka"
+)
+
+// Handler processes a single consumed message
+type Handler func(*ckafka.Message) error
+
+// drainTimeout bounds how long a rebalance waits for the in-flight messages of revoked
+// partitions, well below the default max.poll.interval.ms of five minutes
+const drainTimeout = 30 * time.Second
+
+// partitionKey identifies a topic partition
+type partitionKey struct {
+	topic     string
+	partition int32
+}
+
+// session holds the state of one ConsumeTopics call.
+//
+// The polling goroutine routes each message to a worker chosen by its partition and key, so
+// messages sharing a key are handled in order while different keys and partitions run in
+// parallel. Offsets are committed per partition only up to the first unfinished message.
+type session struct {
+	ctx      context.Context
+	consumer *ckafka.Consumer
+	handler  Handler
+	policy   *RetryPolicy
+	workers  []*worker
+
+	maxInFlight    int
+	commitInterval time.Duration
+	lastCommit     time.Time
+
+	mu         sync.Mutex
+	idle       *sync.Cond
+	partitions map[partitionKey]*partitionState
+}
+
+// ConsumeMessages consumes messages from a topic as a member of the configured consumer group
+func ConsumeMessages(ctx context.Context, topic string, handler Handler) error {
+	return ConsumeTopics(ctx, []string{topic}, handler)
+}
+
+// ConsumeTopics subscribes to the given topics and dispatches every message to handler.
+// The offset of a message is committed only after its handler returns nil or, when a retry
+// policy is configured, after the message has been forwarded to a retry or dead-letter topic.
+// Without a retry policy a failed message holds back the commits of its partition, so it is
+// consumed again after a restart or rebalance.
+func ConsumeTopics(ctx context.Context, topics []string, handler Handler) error {
+	if consumer == nil {
+		return fmt.Errorf("Kafka consumer not initialized")
+	}
+
+	s := newSession(ctx, consumer, handler)
+
+	subscription := append([]string(nil), topics...)
+	if s.policy != nil {
+		for _, topic := range topics {
+			subscription = append(subscription, s.policy.RetryTopics(topic)...)
+		}
+	}
+
+	if err := consumer.SubscribeTopics(subscription, s.rebalance); err != nil {
+		return fmt.Errorf("failed to subscribe consumer to topics %v: %w", subscription, err)
+	}
+	defer func() { _ = consumer.Unsubscribe() }()
+
+	monitorCtx, stopMonitor := context.WithCancel(ctx)
+	var monitor sync.WaitGroup
+	monitor.Add(1)
+	go func() {
+		defer monitor.Done()
+		s.monitorLag(monitorCtx)
+	}()
+	defer func() {
+		stopMonitor()
+		monitor.Wait()
+	}()
+
+	var wg sync.WaitGroup
+	for _, w := range s.workers {
+		wg.Add(1)
+		go func(w *worker) {
+			defer wg.Done()
+			w.run(s.handle)
+		}(w)
+	}
+	defer func() {
+		for _, w := range s.workers {
+			w.close()
+		}
+		wg.Wait()
+		s.commit()
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+			s.maintain()
+
+			e := consumer.Poll(100)
+			if e == nil {
+				continue
+			}
+			switch ev := e.(type) {
+			case *ckafka.Message:
+				s.dispatch(ev)
+			case ckafka.Error:
+				logger.Errorf("Consumer error: %v", ev)
+			default:
+				// ignore other events (stats, etc.)
+			}
+		}
+	}
+}
+
+// ReadsBacklog reports whether consuming topic with the configured consumer group would read
+// the messages published before it joins: the group has committed offsets for every partition
+// of the topic, or auto_offset_reset starts it from the earliest offset
+func ReadsBacklog(ctx context.Context, topic string) (bool, error) {
+	if consumer == nil {
+		return false, fmt.Errorf("Kafka consumer not initialized")
+	}
+	if kafkaConfig.Consumer.AutoOffsetReset == "earliest" {
+		return true, nil
+	}
+
+	timeout := int(adminTimeout / time.Millisecond)
+	md, err := consumer.GetMetadata(&topic, false, timeout)
+	if err != nil {
+		return false, fmt.Errorf("failed to get metadata for %s: %w", topic, err)
+	}
+	tm, ok := md.Topics[topic]
+	if !ok || tm.Error.Code() != ckafka.ErrNoError || len(tm.Partitions) == 0 {
+		// Nothing has been committed for a topic that does not exist yet
+		return false, nil
+	}
+
+	partitions := make([]ckafka.TopicPartition, 0, len(tm.Partitions))
+	for _, p := range tm.Partitions {
+		partitions = append(partitions, ckafka.TopicPartition{Topic: &topic, Partition: p.ID})
+	}
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	committed, err := consumer.Committed(partitions, timeout)
+	if err != nil {
+		return false, fmt.Errorf("failed to get committed offsets for %s: %w", topic, err)
+	}
+	for _, tp := range committed {
+		if tp.Offset < 0 {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// newSession creates a consume session using the package configuration
+func newSession(ctx context.Context, c *ckafka.Consumer, handler Handler) *session {
+	workers := kafkaConfig.Consumer.Workers
+	if workers < 1 {
+		workers = 1
+	}
+	maxInFlight := kafkaConfig.Consumer.MaxInFlight
+	if maxInFlight < 1 {
+		maxInFlight = 1
+	}
+
+	s := &session{
+		ctx:            ctx,
+		consumer:       c,
+		handler:        instrument(handler),
+		policy:         retryPolicy,
+		workers:        make([]*worker, workers),
+		maxInFlight:    maxInFlight,
+		commitInterval: kafkaConfig.Consumer.CommitInterval,
+		lastCommit:     time.Now(),
+		partitions:     make(map[partitionKey]*partitionState),
+	}
+	s.idle = sync.NewCond(&s.mu)
+	for i := range s.workers {
+		s.workers[i] = newWorker()
+	}
+	return s
+}
+
+// dispatch hands a message to its worker, pausing the partition when it has too much in flight
+func (s *session) dispatch(msg *ckafka.Message) {
+	key := partitionKey{*msg.TopicPartition.Topic, msg.TopicPartition.Partition}
+
+	s.mu.Lock()
+	p, ok := s.partitions[key]
+	if !ok {
+		p = newPartitionState(key.topic, key.partition)
+		s.partitions[key] = p
+	}
+
+	// Messages on retry topics wait until their delay has elapsed
+	if s.policy != nil {
+		if due, ok := retryAt(msg); ok && time.Now().Before(due) {
+			p.delayedUntil = due
+			s.mu.Unlock()
+			s.pause(msg.TopicPartition)
+			return
+		}
+	}
+
+	p.dispatched(int64(msg.TopicPartition.Offset))
+	recordConsumed(msg)
+	throttle := p.inFlight >= s.maxInFlight && !p.throttled
+	if throttle {
+		p.throttled = true
+	}
+	s.mu.Unlock()
+
+	s.workers[s.route(msg)].push(msg)
+
+	if throttle {
+		next := msg.TopicPartition
+		next.Offset++
+		s.pause(next)
+	}
+}
+
+// route picks the worker for a message from its partition and key
+func (s *session) route(msg *ckafka.Message) int {
+	var partition [4]byte
+	binary.BigEndian.PutUint32(partition[:], uint32(msg.TopicPartition.Partition))
+
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(*msg.TopicPartition.Topic))
+	_, _ = h.Write(partition[:])
+	_, _ = h.Write(msg.Key)
+	return int(h.Sum32() % uint32(len(s.workers)))
+}
+
+// handle runs on a worker and settles one message
+func (s *session) handle(msg *ckafka.Message) {
+	s.complete(msg, s.settle(msg))
+}
+
+// settle runs the handler and, when it keeps failing, forwards the message according to the
+// retry policy. It reports whether the message's offset may be committed.
+func (s *session) settle(msg *ckafka.Message) bool {
+	if s.ctx.Err() != nil {
+		return false
+	}
+
+	if s.policy == nil {
+		if err := s.handler(msg); err != nil {
+			// Leave the offset pending so the message is consumed again after a restart or rebalance
+			logger.Errorf("Error processing message from %s, holding back its partition: %v", msg.TopicPartition, err)
+			return false
+		}
+		return true
+	}
+
+	err := s.policy.run(s.ctx, s.handler, msg)
+	if err == nil {
+		return true
+	}
+
+	// Keep trying to forward; backpressure pauses the partition in the meantime
+	backoff := s.policy.Backoff
+	for {
+		fwdErr := s.policy.forward(s.ctx, msg, err)
+		if fwdErr == nil {
+			return true
+		}
+		logger.Errorf("Error processing message from %s: %v", msg.TopicPartition, fwdErr)
+
+		// A revoked partition is handed to another member, which consumes the message again
+		if s.revoking(msg) {
+			logger.Warnf("Giving up forwarding message from %s: partition revoked", msg.TopicPartition)
+			return false
+		}
+
+		select {
+		case <-time.After(backoff):
+		case <-s.ctx.Done():
+			return false
+		}
+		if backoff *= 2; s.policy.MaxBackoff > 0 && backoff > s.policy.MaxBackoff {
+			backoff = s.policy.MaxBackoff
+		}
+	}
+}
+
+// revoking reports whether the partition of msg is being revoked or is no longer assigned
+func (s *session) revoking(msg *ckafka.Message) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p, ok := s.partitions[partitionKey{*msg.TopicPartition.Topic, msg.TopicPartition.Partition}]
+	return !ok || p.revoking
+}
+
+// complete records that a worker has finished with a message
+func (s *session) complete(msg *ckafka.Message, ok bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p, found := s.partitions[partitionKey{*msg.TopicPartition.Topic, msg.TopicPartition.Partition}]
+	if !found {
+		return
+	}
+	p.completed(int64(msg.TopicPartition.Offset), ok)
+	if p.inFlight == 0 {
+		s.idle.Broadcast()
+	}
+}
+
+// maintain runs between polls: it resumes paused partitions that may continue and commits
+// completed offsets when the commit interval has elapsed
+func (s *session) maintain() {
+	now := time.Now()
+	var resume []ckafka.TopicPartition
+
+	s.mu.Lock()
+	for _, p := range s.partitions {
+		if !p.delayedUntil.IsZero() && !now.Before(p.delayedUntil) {
+			p.delayedUntil = time.Time{}
+		}
+		if p.throttled && p.inFlight <= s.maxInFlight/2 {
+			p.throttled = false
+		}
+		if p.paused && !p.throttled && p.delayedUntil.IsZero() {
+			topic := p.topic
+			resume = append(resume, ckafka.TopicPartition{Topic: &topic, Partition: p.partition})
+		}
+	}
+	s.mu.Unlock()
+
+	if len(resume) > 0 {
+		if err := s.consumer.Resume(resume); err != nil {
+			logger.Errorf("Failed to resume partitions %v: %v", resume, err)
+		}
+		s.mu.Lock()
+		for _, tp := range resume {
+			if p, ok := s.partitions[partitionKey{*tp.Topic, tp.Partition}]; ok {
+				p.paused = false
+			}
+		}
+		s.mu.Unlock()
+	}
+
+	if now.Sub(s.lastCommit) >= s.commitInterval {
+		s.commit()
+	}
+}
+
+// pause pauses a partition and rewinds it to tp.Offset, the next message to dispatch
+func (s *session) pause(tp ckafka.TopicPartition) {
+	if err := s.consumer.Pause([]ckafka.TopicPartition{tp}); err != nil {
+		logger.Errorf("Failed to pause %s: %v", tp, err)
+		return
+	}
+	// Pausing discards prefetched messages, so continue from tp.Offset once resumed
+	if err := s.consumer.Seek(tp, 0); err != nil {
+		logger.Errorf("Failed to seek to %s: %v", tp, err)
+	}
+
+	s.mu.Lock()
+	if p, ok := s.partitions[partitionKey{*tp.Topic, tp.Partition}]; ok {
+		p.paused = true
+	}
+	s.mu.Unlock()
+}
+
+// commit commits the completed offsets of every partition
+func (s *session) commit() {
+	s.commitPartitions(nil)
+}
+
+// commitPartitions commits the completed offsets of the given partitions, or of all when nil
+func (s *session) commitPartitions(only map[partitionKey]bool) {
+	s.mu.Lock()
+	var offsets []ckafka.TopicPartition
+	for key, p := range s.partitions {
+		if only != nil && !only[key] {
+			continue
+		}
+		if p.next >= 0 {
+			offsets = append(offsets, p.topicPartition())
+			p.next = -1
+		}
+	}
+	s.lastCommit = time.Now()
+	s.mu.Unlock()
+
+	if len(offsets) == 0 {
+		return
+	}
+	if _, err := s.consumer.CommitOffsets(offsets); err != nil {
+		logger.Errorf("Failed to commit offsets %v: %v", offsets, err)
+	}
+}
+
+// drain waits until the given partitions have no messages in flight, or until timeout has
+// passed. It reports whether they were drained; messages still in flight afterwards are left
+// uncommitted.
+func (s *session) drain(keys map[partitionKey]bool, timeout time.Duration) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	timedOut := false
+	timer := time.AfterFunc(timeout, func() {
+		s.mu.Lock()
+		timedOut = true
+		s.mu.Unlock()
+		s.idle.Broadcast()
+	})
+	defer timer.Stop()
+
+	for {
+		busy := false
+		for key := range keys {
+			if p, ok := s.partitions[key]; ok && p.inFlight > 0 {
+				busy = true
+				break
+			}
+		}
+		if !busy {
+			return true
+		}
+		if timedOut {
+			return false
+		}
+		s.idle.Wait()
+	}
+}
+
+// rebalance is invoked from Poll when the group coordinator changes the assignment.
+// Before partitions are revoked their in-flight messages are finished and committed.
+func (s *session) rebalance(c *ckafka.Consumer, e ckafka.Event) error {
+	cooperative := c.GetRebalanceProtocol() == "COOPERATIVE"
+
+	switch ev := e.(type) {
+	case ckafka.AssignedPartitions:
+		logger.Infof("Kafka partitions assigned: %v", ev.Partitions)
+		var err error
+		if cooperative {
+			err = c.IncrementalAssign(ev.Partitions)
+		} else {
+			err = c.Assign(ev.Partitions)
+		}
+		if err != nil {
+			return err
+		}
+		// A partition paused before it was revoked must not stay paused when it comes back
+		return c.Resume(ev.Partitions)
+	case ckafka.RevokedPartitions:
+		revoked := make(map[partitionKey]bool, len(ev.Partitions))
+		s.mu.Lock()
+		for _, tp := range ev.Partitions {
+			key := partitionKey{*tp.Topic, tp.Partition}
+			revoked[key] = true
+			// Stops workers retrying to forward messages of these partitions
+			if p, ok := s.partitions[key]; ok {
+				p.revoking = true
+			}
+		}
+		s.mu.Unlock()
+
+		// Rebalance runs inside Poll, so waiting too long would exceed max.poll.interval.ms
+		// and get this member removed from the group
+		if c.AssignmentLost() {
+			// Another member already owns these partitions, so committing would be rejected
+			logger.Warnf("Kafka partitions lost: %v", ev.Partitions)
+			s.drain(revoked, drainTimeout)
+		} else {
+			logger.Infof("Kafka partitions revoked: %v", ev.Partitions)
+			if !s.drain(revoked, drainTimeout) {
+				logger.Warnf("Kafka partitions %v still busy after %s; their unfinished messages are left uncommitted", ev.Partitions, drainTimeout)
+			}
+			s.commitPartitions(revoked)
+		}
+
+		s.mu.Lock()
+		for key := range revoked {
+			delete(s.partitions, key)
+		}
+		s.mu.Unlock()
+
+		if cooperative {
+			return c.IncrementalUnassign(ev.Partitions)
+		}
+		return c.Unassign()
+	}
+
+	return nil
+}
@@ -0,0 +1,186 @@
+package kafka
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"time"
+
+	"yourapp/internal/global"
+
+	ckafka "github.com/confluentinc/confluent-kafka-go/kafka"
+)
+
+// Envelope headers carried by every typed event
+const (
+	HeaderEventID       = "event-id"
+	HeaderEventType     = "event-type"
+	HeaderEventSource   = "event-source"
+	HeaderEventTime     = "event-time"
+	HeaderSchemaVersion = "schema-version"
+	HeaderContentType   = "content-type"
+	HeaderTraceParent   = "traceparent"
+)
+
+// Envelope holds the metadata of an event. It travels in message headers so the
+// payload stays a plain encoding of the data in any codec.
+type Envelope struct {
+	ID            string
+	Type          string
+	Source        string
+	Time          time.Time
+	SchemaVersion int
+	// TraceParent is a W3C trace context traceparent value
+	TraceParent string
+	ContentType string
+}
+
+// Event is a typed message with its envelope
+type Event[T any] struct {
+	Envelope
+	Key  string
+	Data T
+	// Message is the consumed message; nil for events being published
+	Message *ckafka.Message
+}
+
+type traceParentKey struct{}
+
+// ContextWithTraceParent returns a context carrying a traceparent for published events
+func ContextWithTraceParent(ctx context.Context, traceParent string) context.Context {
+	return context.WithValue(ctx, traceParentKey{}, traceParent)
+}
+
+// TraceParentFromContext returns the traceparent carried by ctx
+func TraceParentFromContext(ctx context.Context) string {
+	tp, _ := ctx.Value(traceParentKey{}).(string)
+	return tp
+}
+
+// PublishEvent encodes ev.Data with codec (JSON when nil) and queues it for delivery with its
+// envelope in the message headers. Empty envelope fields are filled in: a random ID, the
+// current time, the application name as source and the traceparent from ctx.
+func PublishEvent[T any](ctx context.Context, topic string, ev *Event[T], codec Codec) (*Future, error) {
+	m, err := EventMessage(ctx, topic, ev, codec)
+	if err != nil {
+		return nil, err
+	}
+	return Send(ctx, m, nil)
+}
+
+// EventMessage builds the message PublishEvent would send for ev
+func EventMessage[T any](ctx context.Context, topic string, ev *Event[T], codec Codec) (*Message, error) {
+	if codec == nil {
+		codec = JSONCodec
+	}
+
+	value, err := codec.Marshal(ev.Data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode %s event: %w", ev.Type, err)
+	}
+
+	env := ev.Envelope
+	if env.ID == "" {
+		env.ID = newEventID()
+	}
+	if env.Time.IsZero() {
+		env.Time = time.Now()
+	}
+	if env.Source == "" {
+		if cfg := global.GetConfig(); cfg != nil {
+			env.Source = cfg.App.Name
+		}
+	}
+	if env.TraceParent == "" {
+		env.TraceParent = TraceParentFromContext(ctx)
+	}
+	env.ContentType = codec.ContentType()
+
+	m := &Message{Topic: topic, Key: []byte(ev.Key), Value: value, Timestamp: env.Time}
+	m.SetHeader(HeaderEventID, env.ID)
+	m.SetHeader(HeaderEventType, env.Type)
+	m.SetHeader(HeaderEventSource, env.Source)
+	m.SetHeader(HeaderEventTime, env.Time.UTC().Format(time.RFC3339Nano))
+	m.SetHeader(HeaderSchemaVersion, strconv.Itoa(env.SchemaVersion))
+	m.SetHeader(HeaderContentType, env.ContentType)
+	if env.TraceParent != "" {
+		m.SetHeader(HeaderTraceParent, env.TraceParent)
+	}
+
+	return m, nil
+}
+
+// Consume consumes a topic and decodes each message into an Event[T] before calling handler.
+// The codec is chosen from the content-type header unless one is given. The context passed to
+// handler carries the event's traceparent.
+func Consume[T any](ctx context.Context, topic string, codec Codec, handler func(context.Context, *Event[T]) error) error {
+	return ConsumeMessages(ctx, topic, func(msg *ckafka.Message) error {
+		ev, err := DecodeEvent[T](msg, codec)
+		if err != nil {
+			return err
+		}
+		return handler(ContextWithTraceParent(ctx, ev.TraceParent), ev)
+	})
+}
+
+// DecodeEvent decodes a consumed message into an Event[T]
+func DecodeEvent[T any](msg *ckafka.Message, codec Codec) (*Event[T], error) {
+	ev := &Event[T]{Key: string(msg.Key), Message: msg}
+
+	if v, ok := header(msg, HeaderEventID); ok {
+		ev.ID = string(v)
+	}
+	if v, ok := header(msg, HeaderEventType); ok {
+		ev.Type = string(v)
+	}
+	if v, ok := header(msg, HeaderEventSource); ok {
+		ev.Source = string(v)
+	}
+	if v, ok := header(msg, HeaderEventTime); ok {
+		ev.Time, _ = time.Parse(time.RFC3339Nano, string(v))
+	}
+	if ev.Time.IsZero() {
+		ev.Time = msg.Timestamp
+	}
+	ev.SchemaVersion = headerInt(msg, HeaderSchemaVersion)
+	if v, ok := header(msg, HeaderTraceParent); ok {
+		ev.TraceParent = string(v)
+	}
+	if v, ok := header(msg, HeaderContentType); ok {
+		ev.ContentType = string(v)
+	}
+
+	if codec == nil {
+		var err error
+		if codec, err = codecFor(ev.ContentType); err != nil {
+			return nil, fmt.Errorf("failed to decode message %s: %w", msg.TopicPartition, err)
+		}
+	}
+	if err := codec.Unmarshal(msg.Value, &ev.Data); err != nil {
+		return nil, fmt.Errorf("failed to decode message %s: %w", msg.TopicPartition, err)
+	}
+
+	return ev, nil
+}
+
+// newEventID returns a random UUID (version 4)
+func newEventID() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+
+	var s [36]byte
+	hex.Encode(s[0:8], b[0:4])
+	s[8] = '-'
+	hex.Encode(s[9:13], b[4:6])
+	s[13] = '-'
+	hex.Encode(s[14:18], b[6:8])
+	s[18] = '-'
+	hex.Encode(s[19:23], b[8:10])
+	s[23] = '-'
+	hex.Encode(s[24:], b[10:])
+	return string(s[:])
+}
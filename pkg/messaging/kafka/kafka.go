@@ -1,8 +1,17 @@
+// Package kafka connects the application to Kafka through a package-level producer and
+// consumer created by Init.
+//
+// Publishing is asynchronous: Publish (topic, key and value), Send (a Message with headers,
+// partition and timestamp) and PublishEvent (an Event encoded with a Codec) queue the message
+// and return a Future that resolves with the delivery report, which a background goroutine
+// reads from the producer. PublishMessage and SendSync wait for the acknowledgement, and
+// Flush waits for queued messages on shutdown.
 package kafka
 
 import (
 	"context"
 	"fmt"
+	"strings"
 	"time"
 
 	"yourapp/pkg/config"
@@ -12,17 +21,29 @@ import (
 )
 
 var (
-	producer *ckafka.Producer
-	consumer *ckafka.Consumer
+	producer    *ckafka.Producer
+	consumer    *ckafka.Consumer
+	kafkaConfig config.KafkaConfig
+	retryPolicy *RetryPolicy
 )
 
 // Init initializes the Kafka connection
 func Init(ctx context.Context, cfg config.KafkaConfig) error {
+	kafkaConfig = cfg
+	if cfg.Retry.Enabled {
+		retryPolicy = NewRetryPolicy(cfg.Retry)
+	}
+
 	// Initialize producer
 	if err := initProducer(cfg); err != nil {
 		return fmt.Errorf("failed to initialize Kafka producer: %w", err)
 	}
 
+	// Initialize admin client
+	if err := initAdmin(); err != nil {
+		return err
+	}
+
 	// Initialize consumer
 	if err := initConsumer(cfg); err != nil {
 		return fmt.Errorf("failed to initialize Kafka consumer: %w", err)
@@ -33,46 +54,44 @@ func Init(ctx context.Context, cfg config.KafkaConfig) error {
 
 // initProducer initializes the Kafka producer
 func initProducer(cfg config.KafkaConfig) error {
-	conf := &ckafka.ConfigMap{
-		"bootstrap.servers": fmt.Sprintf("%s:%d", cfg.Host, cfg.Port),
-	}
-
-	// Optional security/SASL configs
-	if cfg.SecurityProtocol != "" {
-		_ = conf.SetKey("security.protocol", cfg.SecurityProtocol)
-	}
-	if cfg.SASLMechanism != "" {
-		_ = conf.SetKey("sasl.mechanism", cfg.SASLMechanism)
-	}
-	if cfg.Username != "" {
-		_ = conf.SetKey("sasl.username", cfg.Username)
-	}
-	if cfg.Password != "" {
-		_ = conf.SetKey("sasl.password", cfg.Password)
-	}
-	if cfg.SessionTimeout > 0 {
-		_ = conf.SetKey("session.timeout.ms", int(cfg.SessionTimeout/time.Millisecond))
-	}
-	if cfg.HeartbeatInterval > 0 {
-		_ = conf.SetKey("heartbeat.interval.ms", int(cfg.HeartbeatInterval/time.Millisecond))
+	conf, err := producerConfigMap(cfg)
+	if err != nil {
+		return err
 	}
 
-	var err error
 	producer, err = ckafka.NewProducer(conf)
 	if err != nil {
 		return fmt.Errorf("failed to create Kafka producer: %w", err)
 	}
+	startDeliveryReports(producer)
 
 	return nil
 }
 
 // initConsumer initializes the Kafka consumer
 func initConsumer(cfg config.KafkaConfig) error {
-	conf := &ckafka.ConfigMap{
-		"bootstrap.servers": fmt.Sprintf("%s:%d", cfg.Host, cfg.Port),
-		// No group.id to allow manual partition assignment (Assign)
+	conf, err := consumerConfigMap(cfg, cfg.Consumer.GroupID)
+	if err != nil {
+		return err
 	}
 
+	consumer, err = ckafka.NewConsumer(conf)
+	if err != nil {
+		return fmt.Errorf("failed to create Kafka consumer: %w", err)
+	}
+
+	return nil
+}
+
+// clientConfigMap builds the configuration shared by every client: brokers, security and
+// the extra properties
+func clientConfigMap(cfg config.KafkaConfig) (*ckafka.ConfigMap, error) {
+	brokers := strings.Join(cfg.Brokers, ",")
+	if brokers == "" {
+		brokers = fmt.Sprintf("%s:%d", cfg.Host, cfg.Port)
+	}
+	conf := &ckafka.ConfigMap{"bootstrap.servers": brokers}
+
 	// Optional security/SASL configs
 	if cfg.SecurityProtocol != "" {
 		_ = conf.SetKey("security.protocol", cfg.SecurityProtocol)
@@ -86,19 +105,112 @@ func initConsumer(cfg config.KafkaConfig) error {
 	if cfg.Password != "" {
 		_ = conf.SetKey("sasl.password", cfg.Password)
 	}
-	if cfg.SessionTimeout > 0 {
-		_ = conf.SetKey("session.timeout.ms", int(cfg.SessionTimeout/time.Millisecond))
+
+	// Optional TLS configs
+	if cfg.SSL.CAFile != "" {
+		_ = conf.SetKey("ssl.ca.location", cfg.SSL.CAFile)
+	}
+	if cfg.SSL.CertFile != "" {
+		_ = conf.SetKey("ssl.certificate.location", cfg.SSL.CertFile)
 	}
-	if cfg.HeartbeatInterval > 0 {
-		_ = conf.SetKey("heartbeat.interval.ms", int(cfg.HeartbeatInterval/time.Millisecond))
+	if cfg.SSL.KeyFile != "" {
+		_ = conf.SetKey("ssl.key.location", cfg.SSL.KeyFile)
+	}
+	if cfg.SSL.KeyPassword != "" {
+		_ = conf.SetKey("ssl.key.password", cfg.SSL.KeyPassword)
+	}
+	if cfg.SSL.InsecureSkipVerify {
+		_ = conf.SetKey("enable.ssl.certificate.verification", false)
 	}
 
-	var err error
-	consumer, err = ckafka.NewConsumer(conf)
+	if err := setExtra(conf, cfg.Extra); err != nil {
+		return nil, err
+	}
+	return conf, nil
+}
+
+// producerConfigMap builds the producer configuration
+func producerConfigMap(cfg config.KafkaConfig) (*ckafka.ConfigMap, error) {
+	conf, err := clientConfigMap(cfg)
 	if err != nil {
-		return fmt.Errorf("failed to create Kafka consumer: %w", err)
+		return nil, err
+	}
+	p := cfg.Producer
+
+	// Batching and delivery guarantees
+	if p.Linger > 0 {
+		_ = conf.SetKey("linger.ms", int(p.Linger/time.Millisecond))
+	}
+	if p.BatchSize > 0 {
+		_ = conf.SetKey("batch.size", p.BatchSize)
+	}
+	if p.Compression != "" {
+		_ = conf.SetKey("compression.type", p.Compression)
+	}
+	if p.Acks != "" {
+		_ = conf.SetKey("acks", p.Acks)
+	}
+	_ = conf.SetKey("enable.idempotence", p.Idempotence)
+
+	if err := setExtra(conf, p.Extra); err != nil {
+		return nil, err
+	}
+	return conf, nil
+}
+
+// consumerConfigMap builds the consumer configuration for the given group
+func consumerConfigMap(cfg config.KafkaConfig, groupID string) (*ckafka.ConfigMap, error) {
+	conf, err := clientConfigMap(cfg)
+	if err != nil {
+		return nil, err
+	}
+	c := cfg.Consumer
+
+	// Offsets are committed explicitly once the handler succeeds
+	_ = conf.SetKey("enable.auto.commit", false)
+
+	if groupID != "" {
+		_ = conf.SetKey("group.id", groupID)
+	}
+	if c.AutoOffsetReset != "" {
+		_ = conf.SetKey("auto.offset.reset", c.AutoOffsetReset)
+	}
+	if c.SessionTimeout > 0 {
+		_ = conf.SetKey("session.timeout.ms", int(c.SessionTimeout/time.Millisecond))
+	}
+	if c.HeartbeatInterval > 0 {
+		_ = conf.SetKey("heartbeat.interval.ms", int(c.HeartbeatInterval/time.Millisecond))
 	}
 
+	if err := setExtra(conf, c.Extra); err != nil {
+		return nil, err
+	}
+	return conf, nil
+}
+
+// setExtra copies librdkafka properties into conf. Nested maps are joined with dots, since
+// the config loader turns a key such as socket.keepalive.enable into nested maps.
+func setExtra(conf *ckafka.ConfigMap, extra map[string]any) error {
+	for key, value := range extra {
+		switch v := value.(type) {
+		case map[string]any:
+			nested := make(map[string]any, len(v))
+			for k, nv := range v {
+				nested[key+"."+k] = nv
+			}
+			if err := setExtra(conf, nested); err != nil {
+				return err
+			}
+		case string, bool, int, int64, float64:
+			if err := conf.SetKey(key, v); err != nil {
+				return fmt.Errorf("invalid Kafka property %s: %w", key, err)
+			}
+		default:
+			if err := conf.SetKey(key, fmt.Sprint(v)); err != nil {
+				return fmt.Errorf("invalid Kafka property %s: %w", key, err)
+			}
+		}
+	}
 	return nil
 }
 
@@ -116,8 +228,17 @@ func GetConsumer() *ckafka.Consumer {
 func Close() error {
 	var err error
 
+	if admin != nil {
+		admin.Close()
+	}
+
 	if producer != nil {
+		// Give queued messages a chance to be delivered before closing
+		if flushErr := Flush(kafkaConfig.Producer.FlushTimeout); flushErr != nil {
+			err = flushErr
+		}
 		producer.Close()
+		deliveries.Wait()
 	}
 
 	if consumer != nil {
@@ -133,72 +254,25 @@ func Close() error {
 	return err
 }
 
-// PublishMessage publishes a message to a topic
+// PublishMessage publishes a message to a topic and waits for the broker to acknowledge it
 func PublishMessage(ctx context.Context, topic, key string, message []byte) error {
-	if producer == nil {
-		return fmt.Errorf("Kafka producer not initialized")
-	}
-
-	deliveryChan := make(chan ckafka.Event, 1)
-	defer close(deliveryChan)
-
-	msg := &ckafka.Message{
-		TopicPartition: ckafka.TopicPartition{Topic: &topic, Partition: ckafka.PartitionAny},
-		Key:            []byte(key),
-		Value:          message,
-	}
-
-	if err := producer.Produce(msg, deliveryChan); err != nil {
-		return fmt.Errorf("failed to produce message to topic %s: %w", topic, err)
-	}
-
-	select {
-	case e := <-deliveryChan:
-		m := e.(*ckafka.Message)
-		if m.TopicPartition.Error != nil {
-			return fmt.Errorf("delivery failed: %w", m.TopicPartition.Error)
-		}
-		logger.Infof("Message sent to topic %s, partition %d, offset %v", topic, m.TopicPartition.Partition, m.TopicPartition.Offset)
-	case <-ctx.Done():
-		return ctx.Err()
-	}
-
-	return nil
+	return SendSync(ctx, &Message{Topic: topic, Key: []byte(key), Value: message})
 }
 
-// ConsumeMessages consumes messages from a topic
-func ConsumeMessages(ctx context.Context, topic string, handler func(*ckafka.Message) error) error {
-	if consumer == nil {
-		return fmt.Errorf("Kafka consumer not initialized")
+// produceSync produces a message and waits for its delivery report
+func produceSync(ctx context.Context, msg *ckafka.Message) error {
+	f, err := produce(ctx, msg, nil)
+	if err != nil {
+		return err
 	}
 
-	// Manually assign to partition 0, starting from latest (similar to previous behavior)
-	if err := consumer.Assign([]ckafka.TopicPartition{{Topic: &topic, Partition: 0, Offset: ckafka.OffsetEnd}}); err != nil {
-		return fmt.Errorf("failed to assign consumer to topic %s: %w", topic, err)
+	tp, err := f.Wait(ctx)
+	if err != nil {
+		return err
 	}
-	defer func() { _ = consumer.Unassign() }()
+	logger.Debugf("Message sent to topic %s, partition %d, offset %v", *tp.Topic, tp.Partition, tp.Offset)
 
-	for {
-		select {
-		case <-ctx.Done():
-			return ctx.Err()
-		default:
-			e := consumer.Poll(100)
-			if e == nil {
-				continue
-			}
-			switch ev := e.(type) {
-			case *ckafka.Message:
-				if err := handler(ev); err != nil {
-					logger.Errorf("Error processing message: %v", err)
-				}
-			case ckafka.Error:
-				logger.Errorf("Consumer error: %v", ev)
-			default:
-				// ignore other events (stats, etc.)
-			}
-		}
-	}
+	return nil
 }
 
 // Health checks the health of the Kafka connection
@@ -219,12 +293,3 @@ func Health(ctx context.Context) error {
 
 	return fmt.Errorf("Kafka client not initialized")
 }
-
-// CreateTopic creates a new topic
-func CreateTopic(ctx context.Context, topicName string, numPartitions int32, replicationFactor int16) error {
-	// This would typically be done through Kafka admin API
-	// For now, we'll just log that the topic should be created
-	fmt.Printf("Topic %s should be created with %d partitions and replication factor %d\n",
-		topicName, numPartitions, replicationFactor)
-	return nil
-}
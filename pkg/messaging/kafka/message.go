@@ -0,0 +1,67 @@
+package kafka
+
+import (
+	"context"
+	"time"
+
+	ckafka "github.com/confluentinc/confluent-kafka-go/kafka"
+)
+
+// Message represents a message to publish
+type Message struct {
+	Topic   string
+	Key     []byte
+	Value   []byte
+	Headers []ckafka.Header
+	// Partition selects an explicit partition; nil lets the partitioner choose from Key
+	Partition *int32
+	// Timestamp overrides the message timestamp; zero means the time it is produced
+	Timestamp time.Time
+}
+
+// SetHeader sets a header, replacing any existing header with the same key
+func (m *Message) SetHeader(key, value string) {
+	m.Headers = append(withoutHeaders(m.Headers, key), ckafka.Header{Key: key, Value: []byte(value)})
+}
+
+// Header returns the value of a header
+func (m *Message) Header(key string) (string, bool) {
+	for i := len(m.Headers) - 1; i >= 0; i-- {
+		if m.Headers[i].Key == key {
+			return string(m.Headers[i].Value), true
+		}
+	}
+	return "", false
+}
+
+// kafkaMessage converts the message for the producer
+func (m *Message) kafkaMessage() *ckafka.Message {
+	topic := m.Topic
+	partition := ckafka.PartitionAny
+	if m.Partition != nil {
+		partition = *m.Partition
+	}
+
+	msg := &ckafka.Message{
+		TopicPartition: ckafka.TopicPartition{Topic: &topic, Partition: partition},
+		Key:            m.Key,
+		Value:          m.Value,
+		Headers:        m.Headers,
+	}
+	if !m.Timestamp.IsZero() {
+		msg.Timestamp = m.Timestamp
+		msg.TimestampType = ckafka.TimestampCreateTime
+	}
+	return msg
+}
+
+// Send queues a message for delivery and returns without waiting for the broker.
+// See Publish for the meaning of ctx and callback.
+func Send(ctx context.Context, m *Message, callback DeliveryCallback) (*Future, error) {
+	return produce(ctx, m.kafkaMessage(), callback)
+}
+
+// SendSync publishes a message and waits for the broker to acknowledge it
+func SendSync(ctx context.Context, m *Message) error {
+	return produceSync(ctx, m.kafkaMessage())
+}
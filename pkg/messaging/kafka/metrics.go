@@ -0,0 +1,269 @@
+package kafka
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"yourapp/pkg/logger"
+	"yourapp/pkg/metrics"
+
+	ckafka "github.com/confluentinc/confluent-kafka-go/kafka"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	consumedMessages = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "kafka_consumer_messages_total",
+		Help: "Messages dispatched to handlers.",
+	}, []string{"topic"})
+	handlerErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "kafka_consumer_handler_errors_total",
+		Help: "Handler invocations that returned an error.",
+	}, []string{"topic"})
+	handlerDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "kafka_consumer_handler_duration_seconds",
+		Help:    "Time spent in message handlers.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"topic"})
+	consumerLag = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "kafka_consumer_lag",
+		Help: "High watermark minus committed offset per assigned partition.",
+	}, []string{"topic", "partition"})
+	consumerThroughput = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "kafka_consumer_messages_per_second",
+		Help: "Messages dispatched per second over the last lag interval.",
+	})
+	producedMessages = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "kafka_producer_messages_total",
+		Help: "Delivery reports received, by result.",
+	}, []string{"topic", "result"})
+)
+
+func init() {
+	metrics.Registry.MustRegister(
+		consumedMessages,
+		handlerErrors,
+		handlerDuration,
+		consumerLag,
+		consumerThroughput,
+		producedMessages,
+	)
+}
+
+// PartitionLag is the lag of one assigned partition
+type PartitionLag struct {
+	Topic         string `json:"topic"`
+	Partition     int32  `json:"partition"`
+	Committed     int64  `json:"committed"`
+	HighWatermark int64  `json:"high_watermark"`
+	Lag           int64  `json:"lag"`
+}
+
+// ConsumerStats is a snapshot of the consumer's lag and throughput
+type ConsumerStats struct {
+	Partitions        []PartitionLag `json:"partitions"`
+	TotalLag          int64          `json:"total_lag"`
+	MaxLag            int64          `json:"max_lag"`
+	MessagesPerSecond float64        `json:"messages_per_second"`
+	Messages          uint64         `json:"messages"`
+	HandlerErrors     uint64         `json:"handler_errors"`
+	LagThreshold      int64          `json:"lag_threshold"`
+	// Degraded is set when a partition lags by more than LagThreshold messages
+	Degraded  bool      `json:"degraded"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+var (
+	messageCount atomic.Uint64
+	errorCount   atomic.Uint64
+
+	statsMu sync.RWMutex
+	stats   ConsumerStats
+)
+
+// Stats returns the consumer statistics from the last lag measurement
+func Stats() ConsumerStats {
+	statsMu.RLock()
+	defer statsMu.RUnlock()
+
+	s := stats
+	s.Partitions = append([]PartitionLag(nil), stats.Partitions...)
+	s.Messages = messageCount.Load()
+	s.HandlerErrors = errorCount.Load()
+	return s
+}
+
+// instrument wraps a handler to record its latency and errors
+func instrument(handler Handler) Handler {
+	return func(msg *ckafka.Message) error {
+		topic := *msg.TopicPartition.Topic
+		start := time.Now()
+		err := handler(msg)
+		handlerDuration.WithLabelValues(topic).Observe(time.Since(start).Seconds())
+		if err != nil {
+			errorCount.Add(1)
+			handlerErrors.WithLabelValues(topic).Inc()
+		}
+		return err
+	}
+}
+
+// recordConsumed counts a message dispatched to a worker
+func recordConsumed(msg *ckafka.Message) {
+	messageCount.Add(1)
+	consumedMessages.WithLabelValues(*msg.TopicPartition.Topic).Inc()
+}
+
+// recordDelivery counts a delivery report
+func recordDelivery(tp ckafka.TopicPartition) {
+	result := "success"
+	if tp.Error != nil {
+		result = "error"
+	}
+	topic := ""
+	if tp.Topic != nil {
+		topic = *tp.Topic
+	}
+	producedMessages.WithLabelValues(topic, result).Inc()
+}
+
+// monitorLag measures the lag of the assigned partitions every lag interval until ctx is done
+func (s *session) monitorLag(ctx context.Context) {
+	interval := kafkaConfig.Consumer.LagInterval
+	if interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	tracked := make(map[partitionKey]bool)
+	last, lastCount := time.Now(), messageCount.Load()
+	for {
+		select {
+		case <-ctx.Done():
+			for key := range tracked {
+				consumerLag.DeleteLabelValues(key.topic, strconv.Itoa(int(key.partition)))
+			}
+			return
+		case now := <-ticker.C:
+			count := messageCount.Load()
+			rate := float64(count-lastCount) / now.Sub(last).Seconds()
+			last, lastCount = now, count
+			consumerThroughput.Set(rate)
+
+			lags, err := s.measureLag()
+			if err != nil {
+				logger.Warnf("Failed to measure Kafka consumer lag: %v", err)
+				continue
+			}
+
+			current := make(map[partitionKey]bool, len(lags))
+			for _, l := range lags {
+				current[partitionKey{l.Topic, l.Partition}] = true
+				consumerLag.WithLabelValues(l.Topic, strconv.Itoa(int(l.Partition))).Set(float64(l.Lag))
+			}
+			for key := range tracked {
+				if !current[key] {
+					consumerLag.DeleteLabelValues(key.topic, strconv.Itoa(int(key.partition)))
+				}
+			}
+			tracked = current
+
+			updateStats(lags, rate, now)
+		}
+	}
+}
+
+// measureLag compares the committed offset of every assigned partition with its high watermark
+// as reported by the partition leader. A partition without a committed offset is measured from
+// the consumer's position, or from where auto_offset_reset starts it before the first fetch.
+func (s *session) measureLag() ([]PartitionLag, error) {
+	assignment, err := s.consumer.Assignment()
+	if err != nil {
+		return nil, err
+	}
+	if len(assignment) == 0 {
+		return nil, nil
+	}
+
+	committed, err := s.consumer.Committed(assignment, int(adminTimeout/time.Millisecond))
+	if err != nil {
+		return nil, err
+	}
+	positions, err := s.consumer.Position(assignment)
+	if err != nil {
+		return nil, err
+	}
+	position := make(map[partitionKey]ckafka.Offset, len(positions))
+	for _, tp := range positions {
+		position[partitionKey{*tp.Topic, tp.Partition}] = tp.Offset
+	}
+
+	lags := make([]PartitionLag, 0, len(committed))
+	for _, tp := range committed {
+		// Ask the broker; the watermarks cached by the consumer are only refreshed by
+		// statistics and are unset (-1001) until then
+		low, high, err := s.consumer.QueryWatermarkOffsets(*tp.Topic, tp.Partition, int(adminTimeout/time.Millisecond))
+		if err != nil {
+			return nil, fmt.Errorf("failed to query watermarks of %s[%d]: %w", *tp.Topic, tp.Partition, err)
+		}
+
+		offset := int64(tp.Offset)
+		if offset < 0 {
+			offset = int64(position[partitionKey{*tp.Topic, tp.Partition}])
+		}
+		if offset < 0 {
+			// Nothing fetched yet: the group starts at the end unless it reads from the start
+			offset = high
+			if kafkaConfig.Consumer.AutoOffsetReset == "earliest" {
+				offset = low
+			}
+		}
+		lag := high - offset
+		if lag < 0 {
+			lag = 0
+		}
+		lags = append(lags, PartitionLag{
+			Topic:         *tp.Topic,
+			Partition:     tp.Partition,
+			Committed:     int64(tp.Offset),
+			HighWatermark: high,
+			Lag:           lag,
+		})
+	}
+
+	sort.Slice(lags, func(i, j int) bool {
+		if lags[i].Topic != lags[j].Topic {
+			return lags[i].Topic < lags[j].Topic
+		}
+		return lags[i].Partition < lags[j].Partition
+	})
+	return lags, nil
+}
+
+// updateStats stores a new lag measurement
+func updateStats(lags []PartitionLag, rate float64, now time.Time) {
+	next := ConsumerStats{
+		Partitions:        lags,
+		MessagesPerSecond: rate,
+		LagThreshold:      kafkaConfig.Consumer.LagThreshold,
+		UpdatedAt:         now,
+	}
+	for _, l := range lags {
+		next.TotalLag += l.Lag
+		if l.Lag > next.MaxLag {
+			next.MaxLag = l.Lag
+		}
+	}
+	next.Degraded = next.LagThreshold > 0 && next.MaxLag > next.LagThreshold
+
+	statsMu.Lock()
+	stats = next
+	statsMu.Unlock()
+}
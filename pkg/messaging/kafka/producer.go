@@ -0,0 +1,129 @@
+package kafka
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"yourapp/pkg/logger"
+
+	ckafka "github.com/confluentinc/confluent-kafka-go/kafka"
+)
+
+// DeliveryCallback is called from the delivery goroutine once a published message has
+// been acknowledged by the broker or has failed for good
+type DeliveryCallback func(tp ckafka.TopicPartition, err error)
+
+// Future resolves when a published message has been acknowledged or has failed
+type Future struct {
+	done     chan struct{}
+	callback DeliveryCallback
+	tp       ckafka.TopicPartition
+	err      error
+}
+
+// Done returns a channel that is closed once the delivery report has arrived
+func (f *Future) Done() <-chan struct{} {
+	return f.done
+}
+
+// Wait blocks until the delivery report arrives or ctx is done.
+// Cancelling ctx only stops waiting; the message may still be delivered.
+func (f *Future) Wait(ctx context.Context) (ckafka.TopicPartition, error) {
+	select {
+	case <-f.done:
+		return f.tp, f.err
+	case <-ctx.Done():
+		return ckafka.TopicPartition{}, ctx.Err()
+	}
+}
+
+// resolve records the delivery report and runs the callback
+func (f *Future) resolve(tp ckafka.TopicPartition, err error) {
+	f.tp, f.err = tp, err
+	close(f.done)
+	if f.callback != nil {
+		f.callback(tp, err)
+	}
+}
+
+var deliveries sync.WaitGroup
+
+// startDeliveryReports drains the producer's event channel until the producer is closed
+func startDeliveryReports(p *ckafka.Producer) {
+	deliveries.Add(1)
+	go func() {
+		defer deliveries.Done()
+		for e := range p.Events() {
+			switch ev := e.(type) {
+			case *ckafka.Message:
+				recordDelivery(ev.TopicPartition)
+				f, ok := ev.Opaque.(*Future)
+				if !ok {
+					continue
+				}
+				var err error
+				if ev.TopicPartition.Error != nil {
+					err = fmt.Errorf("delivery failed: %w", ev.TopicPartition.Error)
+				}
+				f.resolve(ev.TopicPartition, err)
+			case ckafka.Error:
+				if ev.IsFatal() {
+					logger.Errorf("Kafka producer fatal error: %v", ev)
+				} else {
+					logger.Warnf("Kafka producer error: %v", ev)
+				}
+			default:
+				// ignore other events (stats, etc.)
+			}
+		}
+	}()
+}
+
+// Publish queues a message for delivery and returns without waiting for the broker; it is
+// the asynchronous counterpart of PublishMessage.
+// The returned future resolves with the delivery report; callback, if not nil, is called
+// with it as well. ctx bounds only how long Publish waits when the local queue is full.
+func Publish(ctx context.Context, topic, key string, value []byte, callback DeliveryCallback) (*Future, error) {
+	return Send(ctx, &Message{Topic: topic, Key: []byte(key), Value: value}, callback)
+}
+
+// produce queues msg, retrying while the producer queue is full
+func produce(ctx context.Context, msg *ckafka.Message, callback DeliveryCallback) (*Future, error) {
+	if producer == nil {
+		return nil, fmt.Errorf("Kafka producer not initialized")
+	}
+
+	f := &Future{done: make(chan struct{}), callback: callback}
+	msg.Opaque = f
+
+	for {
+		err := producer.Produce(msg, nil)
+		if err == nil {
+			return f, nil
+		}
+
+		if kerr, ok := err.(ckafka.Error); !ok || kerr.Code() != ckafka.ErrQueueFull {
+			return nil, fmt.Errorf("failed to produce message to topic %s: %w", *msg.TopicPartition.Topic, err)
+		}
+
+		select {
+		case <-time.After(10 * time.Millisecond):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// Flush waits up to timeout for all queued messages to be delivered
+func Flush(timeout time.Duration) error {
+	if producer == nil {
+		return nil
+	}
+
+	if remaining := producer.Flush(int(timeout / time.Millisecond)); remaining > 0 {
+		return fmt.Errorf("%d Kafka messages still undelivered after %v", remaining, timeout)
+	}
+	return nil
+}
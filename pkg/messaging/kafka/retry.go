@@ -0,0 +1,322 @@
+package kafka
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"yourapp/pkg/config"
+	"yourapp/pkg/logger"
+
+	ckafka "github.com/confluentinc/confluent-kafka-go/kafka"
+)
+
+// Headers attached to messages forwarded to retry and dead-letter topics
+const (
+	HeaderOriginalTopic     = "x-original-topic"
+	HeaderOriginalPartition = "x-original-partition"
+	HeaderOriginalOffset    = "x-original-offset"
+	HeaderError             = "x-error"
+	HeaderAttempts          = "x-attempts"
+	HeaderRetryAt           = "x-retry-at"
+	HeaderReplayedFrom      = "x-replayed-from"
+)
+
+// replayIdleTimeout ends a DLQ replay when no message arrives for this long
+const replayIdleTimeout = 10 * time.Second
+
+// RetryPolicy describes how a failing message is retried before it lands in the dead-letter topic.
+//
+// Each delivery runs the handler up to Attempts times with exponential backoff. When all of
+// them fail the message is republished to the next retry topic (one per entry in Delays) and
+// consumed again once its delay has elapsed. After the last retry topic it goes to the DLQ.
+type RetryPolicy struct {
+	Attempts    int
+	Backoff     time.Duration
+	MaxBackoff  time.Duration
+	Delays      []time.Duration
+	RetrySuffix string
+	DLQSuffix   string
+}
+
+// NewRetryPolicy creates a retry policy from configuration
+func NewRetryPolicy(cfg config.KafkaRetryConfig) *RetryPolicy {
+	p := &RetryPolicy{
+		Attempts:    cfg.Attempts,
+		Backoff:     cfg.Backoff,
+		MaxBackoff:  cfg.MaxBackoff,
+		Delays:      cfg.Delays,
+		RetrySuffix: cfg.RetrySuffix,
+		DLQSuffix:   cfg.DLQSuffix,
+	}
+	if p.Attempts < 1 {
+		p.Attempts = 1
+	}
+	if p.RetrySuffix == "" {
+		p.RetrySuffix = ".retry"
+	}
+	if p.DLQSuffix == "" {
+		p.DLQSuffix = ".dlq"
+	}
+	return p
+}
+
+// RetryTopic returns the name of the n-th (1-based) retry topic for a source topic
+func (p *RetryPolicy) RetryTopic(topic string, n int) string {
+	return fmt.Sprintf("%s%s.%d", topic, p.RetrySuffix, n)
+}
+
+// RetryTopics returns all retry topics for a source topic
+func (p *RetryPolicy) RetryTopics(topic string) []string {
+	topics := make([]string, 0, len(p.Delays))
+	for i := range p.Delays {
+		topics = append(topics, p.RetryTopic(topic, i+1))
+	}
+	return topics
+}
+
+// DLQTopic returns the dead-letter topic for a source topic
+func (p *RetryPolicy) DLQTopic(topic string) string {
+	return topic + p.DLQSuffix
+}
+
+// run invokes handler up to Attempts times, backing off between failures
+func (p *RetryPolicy) run(ctx context.Context, handler Handler, msg *ckafka.Message) error {
+	backoff := p.Backoff
+	var err error
+	for attempt := 1; attempt <= p.Attempts; attempt++ {
+		if err = handler(msg); err == nil {
+			return nil
+		}
+		if attempt == p.Attempts {
+			break
+		}
+
+		logger.Warnf("Handler failed for %s (attempt %d/%d): %v", msg.TopicPartition, attempt, p.Attempts, err)
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return err
+		}
+		backoff *= 2
+		if p.MaxBackoff > 0 && backoff > p.MaxBackoff {
+			backoff = p.MaxBackoff
+		}
+	}
+	return err
+}
+
+// forward republishes a message whose handler kept failing to the next retry topic or to the DLQ
+func (p *RetryPolicy) forward(ctx context.Context, msg *ckafka.Message, handlerErr error) error {
+	origin, partition, offset := originOf(msg)
+	attempts := headerInt(msg, HeaderAttempts) + 1
+
+	target := p.DLQTopic(origin)
+	var retryAt time.Time
+	if attempts <= len(p.Delays) {
+		target = p.RetryTopic(origin, attempts)
+		retryAt = time.Now().Add(p.Delays[attempts-1])
+	}
+
+	headers := withoutHeaders(msg.Headers, HeaderOriginalTopic, HeaderOriginalPartition,
+		HeaderOriginalOffset, HeaderError, HeaderAttempts, HeaderRetryAt)
+	headers = append(headers,
+		ckafka.Header{Key: HeaderOriginalTopic, Value: []byte(origin)},
+		ckafka.Header{Key: HeaderOriginalPartition, Value: []byte(strconv.Itoa(int(partition)))},
+		ckafka.Header{Key: HeaderOriginalOffset, Value: []byte(strconv.FormatInt(offset, 10))},
+		ckafka.Header{Key: HeaderError, Value: []byte(handlerErr.Error())},
+		ckafka.Header{Key: HeaderAttempts, Value: []byte(strconv.Itoa(attempts))},
+	)
+	if !retryAt.IsZero() {
+		headers = append(headers, ckafka.Header{Key: HeaderRetryAt, Value: []byte(strconv.FormatInt(retryAt.UnixMilli(), 10))})
+	}
+
+	out := &ckafka.Message{
+		TopicPartition: ckafka.TopicPartition{Topic: &target, Partition: ckafka.PartitionAny},
+		Key:            msg.Key,
+		Value:          msg.Value,
+		Headers:        headers,
+	}
+	if err := produceSync(ctx, out); err != nil {
+		return fmt.Errorf("failed to forward message to %s: %w", target, err)
+	}
+
+	logger.Warnf("Message %s forwarded to %s after %d failed deliveries: %v", msg.TopicPartition, target, attempts, handlerErr)
+	return nil
+}
+
+// retryAt returns when a message read from a retry topic becomes due
+func retryAt(msg *ckafka.Message) (time.Time, bool) {
+	v, ok := header(msg, HeaderRetryAt)
+	if !ok {
+		return time.Time{}, false
+	}
+	ms, err := strconv.ParseInt(string(v), 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.UnixMilli(ms), true
+}
+
+// originOf returns the topic, partition and offset a message was first consumed from
+func originOf(msg *ckafka.Message) (string, int32, int64) {
+	topic, ok := header(msg, HeaderOriginalTopic)
+	if !ok {
+		return *msg.TopicPartition.Topic, msg.TopicPartition.Partition, int64(msg.TopicPartition.Offset)
+	}
+	return string(topic), int32(headerInt(msg, HeaderOriginalPartition)), int64(headerInt(msg, HeaderOriginalOffset))
+}
+
+// header returns the last value of the named header
+func header(msg *ckafka.Message, key string) ([]byte, bool) {
+	for i := len(msg.Headers) - 1; i >= 0; i-- {
+		if msg.Headers[i].Key == key {
+			return msg.Headers[i].Value, true
+		}
+	}
+	return nil, false
+}
+
+// headerInt returns the named header parsed as an integer, or 0
+func headerInt(msg *ckafka.Message, key string) int {
+	v, ok := header(msg, key)
+	if !ok {
+		return 0
+	}
+	n, _ := strconv.Atoi(string(v))
+	return n
+}
+
+// withoutHeaders returns a copy of headers without the given keys
+func withoutHeaders(headers []ckafka.Header, keys ...string) []ckafka.Header {
+	out := make([]ckafka.Header, 0, len(headers))
+	for _, h := range headers {
+		drop := false
+		for _, k := range keys {
+			if h.Key == k {
+				drop = true
+				break
+			}
+		}
+		if !drop {
+			out = append(out, h)
+		}
+	}
+	return out
+}
+
+// ReplayDLQ republishes the messages currently in a dead-letter topic to the topic they were
+// originally consumed from. Progress is committed under a dedicated consumer group, so an
+// interrupted replay resumes where it stopped. It returns the number of replayed messages.
+func ReplayDLQ(ctx context.Context, dlqTopic string, limit int) (int, error) {
+	if producer == nil {
+		return 0, fmt.Errorf("Kafka producer not initialized")
+	}
+
+	groupID := kafkaConfig.Consumer.GroupID + ".dlq-replay"
+	conf, err := consumerConfigMap(kafkaConfig, groupID)
+	if err != nil {
+		return 0, err
+	}
+	_ = conf.SetKey("auto.offset.reset", "earliest")
+
+	c, err := ckafka.NewConsumer(conf)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create DLQ replay consumer: %w", err)
+	}
+	defer func() { _ = c.Close() }()
+
+	md, err := c.GetMetadata(&dlqTopic, false, int((10*time.Second)/time.Millisecond))
+	if err != nil {
+		return 0, fmt.Errorf("failed to get metadata for %s: %w", dlqTopic, err)
+	}
+	tm, ok := md.Topics[dlqTopic]
+	if !ok || tm.Error.Code() != ckafka.ErrNoError {
+		return 0, fmt.Errorf("DLQ topic %s not found", dlqTopic)
+	}
+
+	// Replay up to the high watermark observed now so newly dead-lettered messages are left alone
+	var assignment []ckafka.TopicPartition
+	remaining := make(map[int32]int64)
+	for _, p := range tm.Partitions {
+		_, high, err := c.QueryWatermarkOffsets(dlqTopic, p.ID, int((10*time.Second)/time.Millisecond))
+		if err != nil {
+			return 0, fmt.Errorf("failed to query watermarks for %s[%d]: %w", dlqTopic, p.ID, err)
+		}
+		assignment = append(assignment, ckafka.TopicPartition{Topic: &dlqTopic, Partition: p.ID, Offset: ckafka.OffsetStored})
+		remaining[p.ID] = high
+	}
+
+	committed, err := c.Committed(assignment, int((10*time.Second)/time.Millisecond))
+	if err != nil {
+		return 0, fmt.Errorf("failed to get committed offsets for %s: %w", dlqTopic, err)
+	}
+	for _, tp := range committed {
+		if tp.Offset >= 0 && int64(tp.Offset) >= remaining[tp.Partition] {
+			delete(remaining, tp.Partition)
+		}
+	}
+	if len(remaining) == 0 {
+		return 0, nil
+	}
+
+	if err := c.Assign(assignment); err != nil {
+		return 0, fmt.Errorf("failed to assign %s: %w", dlqTopic, err)
+	}
+
+	replayed := 0
+	lastMessage := time.Now()
+	for len(remaining) > 0 && (limit <= 0 || replayed < limit) {
+		if err := ctx.Err(); err != nil {
+			return replayed, err
+		}
+
+		e := c.Poll(100)
+		msg, ok := e.(*ckafka.Message)
+		if !ok {
+			if kerr, isErr := e.(ckafka.Error); isErr {
+				logger.Errorf("DLQ replay consumer error: %v", kerr)
+			}
+			// Offsets below the watermark may be unreadable (compaction, transaction markers)
+			if time.Since(lastMessage) > replayIdleTimeout {
+				break
+			}
+			continue
+		}
+		lastMessage = time.Now()
+
+		high, tracked := remaining[msg.TopicPartition.Partition]
+		if !tracked || int64(msg.TopicPartition.Offset) >= high {
+			continue
+		}
+
+		origin, ok := header(msg, HeaderOriginalTopic)
+		if !ok {
+			logger.Warnf("Skipping DLQ message %s without %s header", msg.TopicPartition, HeaderOriginalTopic)
+		} else {
+			target := string(origin)
+			out := &ckafka.Message{
+				TopicPartition: ckafka.TopicPartition{Topic: &target, Partition: ckafka.PartitionAny},
+				Key:            msg.Key,
+				Value:          msg.Value,
+				Headers: append(withoutHeaders(msg.Headers, HeaderOriginalTopic, HeaderOriginalPartition,
+					HeaderOriginalOffset, HeaderError, HeaderAttempts, HeaderRetryAt, HeaderReplayedFrom),
+					ckafka.Header{Key: HeaderReplayedFrom, Value: []byte(dlqTopic)}),
+			}
+			if err := produceSync(ctx, out); err != nil {
+				return replayed, fmt.Errorf("failed to replay %s to %s: %w", msg.TopicPartition, target, err)
+			}
+			replayed++
+		}
+
+		if _, err := c.CommitMessage(msg); err != nil {
+			return replayed, fmt.Errorf("failed to commit DLQ offset %s: %w", msg.TopicPartition, err)
+		}
+		if int64(msg.TopicPartition.Offset)+1 >= high {
+			delete(remaining, msg.TopicPartition.Partition)
+		}
+	}
+
+	return replayed, nil
+}
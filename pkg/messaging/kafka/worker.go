@@ -0,0 +1,119 @@
+package kafka
+
+import (
+	"sync"
+	"time"
+
+	ckafka "github.com/confluentinc/confluent-kafka-go/kafka"
+)
+
+// worker processes the messages routed to it one at a time, in arrival order
+type worker struct {
+	mu     sync.Mutex
+	cond   *sync.Cond
+	queue  []*ckafka.Message
+	closed bool
+}
+
+// newWorker creates an idle worker
+func newWorker() *worker {
+	w := &worker{}
+	w.cond = sync.NewCond(&w.mu)
+	return w
+}
+
+// push queues a message for the worker
+func (w *worker) push(msg *ckafka.Message) {
+	w.mu.Lock()
+	w.queue = append(w.queue, msg)
+	w.mu.Unlock()
+	w.cond.Signal()
+}
+
+// close stops the worker once its queue is empty
+func (w *worker) close() {
+	w.mu.Lock()
+	w.closed = true
+	w.mu.Unlock()
+	w.cond.Signal()
+}
+
+// run processes queued messages until the worker is closed
+func (w *worker) run(process func(*ckafka.Message)) {
+	for {
+		w.mu.Lock()
+		for len(w.queue) == 0 && !w.closed {
+			w.cond.Wait()
+		}
+		if len(w.queue) == 0 {
+			w.mu.Unlock()
+			return
+		}
+		msg := w.queue[0]
+		w.queue[0] = nil
+		w.queue = w.queue[1:]
+		w.mu.Unlock()
+
+		process(msg)
+	}
+}
+
+// partitionState tracks the messages of one partition that have been handed to workers
+type partitionState struct {
+	topic     string
+	partition int32
+
+	// pending holds dispatched offsets in ascending order until everything before them is done
+	pending []int64
+	done    map[int64]bool
+	// next is the offset to commit, or -1 when nothing has completed since the last commit
+	next     int64
+	inFlight int
+
+	// paused is set while the partition is paused for either of the reasons below
+	paused bool
+	// throttled is set while the partition is paused because inFlight reached the limit
+	throttled bool
+	// delayedUntil is set while the partition is paused waiting for a retry message to become due
+	delayedUntil time.Time
+	// revoking is set while the partition is being revoked
+	revoking bool
+}
+
+// newPartitionState creates an empty partition tracker
+func newPartitionState(topic string, partition int32) *partitionState {
+	return &partitionState{
+		topic:     topic,
+		partition: partition,
+		done:      make(map[int64]bool),
+		next:      -1,
+	}
+}
+
+// dispatched records that offset has been handed to a worker
+func (p *partitionState) dispatched(offset int64) {
+	p.pending = append(p.pending, offset)
+	p.inFlight++
+}
+
+// completed records that the message at offset is finished. Unless ok is true the
+// offset stays pending, which holds back commits for this partition.
+func (p *partitionState) completed(offset int64, ok bool) {
+	p.inFlight--
+	if !ok {
+		return
+	}
+
+	p.done[offset] = true
+	for len(p.pending) > 0 && p.done[p.pending[0]] {
+		delete(p.done, p.pending[0])
+		p.next = p.pending[0] + 1
+		p.pending = p.pending[1:]
+	}
+}
+
+// topicPartition returns the partition with the offset to commit
+func (p *partitionState) topicPartition() ckafka.TopicPartition {
+	topic := p.topic
+	return ckafka.TopicPartition{Topic: &topic, Partition: p.partition, Offset: ckafka.Offset(p.next)}
+}
@@ -0,0 +1,351 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"yourapp/pkg/config"
+	"yourapp/pkg/logger"
+	"yourapp/pkg/messaging/kafka"
+
+	ckafka "github.com/confluentinc/confluent-kafka-go/kafka"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Record statuses
+const (
+	StatusPending = "pending"
+	StatusSent    = "sent"
+	StatusFailed  = "failed"
+)
+
+// Record is a message waiting in the outbox table to be published to Kafka
+type Record struct {
+	ID        uint64 `gorm:"primaryKey;autoIncrement"`
+	Topic     string `gorm:"size:255;not null"`
+	Key       []byte
+	Value     []byte
+	Headers   []byte // JSON encoded []kafka header
+	Partition *int32
+	Status    string `gorm:"size:16;not null;default:pending;index:idx_outbox_status_id,priority:1"`
+	Attempts  int    `gorm:"not null;default:0"`
+	LastError string `gorm:"size:1024"`
+	// ClaimedUntil is set while a relay publishes the record
+	ClaimedUntil *time.Time
+	CreatedAt    time.Time
+	SentAt       *time.Time `gorm:"index"`
+}
+
+// TableName returns the outbox table name
+func (Record) TableName() string {
+	return "outbox_messages"
+}
+
+// claimLease is how long a relay owns the records it claimed. Publishing waits no longer,
+// and records of a relay that died are claimed again once it has passed.
+const claimLease = 5 * time.Minute
+
+var (
+	relayCancel context.CancelFunc
+	relayDone   chan struct{}
+)
+
+// Init creates the outbox table if needed and starts the relay worker on db
+func Init(ctx context.Context, cfg config.OutboxConfig, db *gorm.DB) error {
+	if db == nil {
+		return fmt.Errorf("outbox database %q not initialized", cfg.Database)
+	}
+
+	if err := Migrate(db); err != nil {
+		return err
+	}
+
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = time.Second
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 100
+	}
+	if cfg.CleanupInterval <= 0 {
+		cfg.CleanupInterval = time.Hour
+	}
+
+	relayCtx, cancel := context.WithCancel(context.Background())
+	relayCancel = cancel
+	relayDone = make(chan struct{})
+
+	r := &relay{db: db, cfg: cfg}
+	go func() {
+		defer close(relayDone)
+		r.run(relayCtx)
+	}()
+
+	return nil
+}
+
+// Close stops the relay worker and waits for the batch in progress
+func Close() error {
+	if relayCancel != nil {
+		relayCancel()
+		<-relayDone
+		relayCancel = nil
+	}
+	return nil
+}
+
+// Migrate creates or updates the outbox table
+func Migrate(db *gorm.DB) error {
+	if err := db.AutoMigrate(&Record{}); err != nil {
+		return fmt.Errorf("failed to migrate outbox table: %w", err)
+	}
+	return nil
+}
+
+// Enqueue stores a message in the outbox using tx, normally the transaction that writes the
+// business data. The relay publishes it once the transaction has committed.
+func Enqueue(tx *gorm.DB, m *kafka.Message) error {
+	headers, err := json.Marshal(m.Headers)
+	if err != nil {
+		return fmt.Errorf("failed to encode outbox headers: %w", err)
+	}
+
+	record := &Record{
+		Topic:     m.Topic,
+		Key:       m.Key,
+		Value:     m.Value,
+		Headers:   headers,
+		Partition: m.Partition,
+		Status:    StatusPending,
+	}
+	if err := tx.Create(record).Error; err != nil {
+		return fmt.Errorf("failed to enqueue outbox message for topic %s: %w", m.Topic, err)
+	}
+
+	return nil
+}
+
+// EnqueueEvent stores a typed event in the outbox using tx
+func EnqueueEvent[T any](ctx context.Context, tx *gorm.DB, topic string, ev *kafka.Event[T], codec kafka.Codec) error {
+	m, err := kafka.EventMessage(ctx, topic, ev, codec)
+	if err != nil {
+		return err
+	}
+	return Enqueue(tx, m)
+}
+
+// message converts a record back into a Kafka message
+func (r *Record) message() (*kafka.Message, error) {
+	m := &kafka.Message{Topic: r.Topic, Key: r.Key, Value: r.Value, Partition: r.Partition}
+	if len(r.Headers) > 0 {
+		var headers []ckafka.Header
+		if err := json.Unmarshal(r.Headers, &headers); err != nil {
+			return nil, fmt.Errorf("failed to decode outbox headers of record %d: %w", r.ID, err)
+		}
+		m.Headers = headers
+	}
+	return m, nil
+}
+
+// relay publishes pending outbox records to Kafka
+type relay struct {
+	db  *gorm.DB
+	cfg config.OutboxConfig
+}
+
+// run relays batches until ctx is cancelled
+func (r *relay) run(ctx context.Context) {
+	poll := time.NewTicker(r.cfg.PollInterval)
+	defer poll.Stop()
+	cleanup := time.NewTicker(r.cfg.CleanupInterval)
+	defer cleanup.Stop()
+
+	for {
+		// Drain the backlog without waiting between full batches
+		for {
+			n, err := r.relayBatch(ctx)
+			if err != nil {
+				if ctx.Err() == nil {
+					logger.Errorf("Outbox relay failed: %v", err)
+				}
+				break
+			}
+			if n < r.cfg.BatchSize {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-cleanup.C:
+			r.cleanup(ctx)
+		case <-poll.C:
+		}
+	}
+}
+
+// relayBatch publishes the oldest pending records in id order and records the outcome. It
+// returns the number of records sent.
+//
+// Records are claimed in one short transaction, published without holding row locks, then
+// settled in a second transaction. A relay backs off while the oldest pending records are
+// claimed by another instance, so only one relay publishes at a time and order is kept.
+func (r *relay) relayBatch(ctx context.Context) (int, error) {
+	records, err := r.claim(ctx)
+	if err != nil || len(records) == 0 {
+		return 0, err
+	}
+
+	publishCtx, cancel := context.WithTimeout(ctx, claimLease)
+	defer cancel()
+
+	// Queue the whole batch first; the idempotent producer keeps per-partition order
+	futures := make([]*kafka.Future, len(records))
+	errs := make([]error, len(records))
+	for i := range records {
+		m, err := records[i].message()
+		if err != nil {
+			errs[i] = err
+			continue
+		}
+		futures[i], errs[i] = kafka.Send(publishCtx, m, nil)
+	}
+
+	// Stop at the first failure; the records after it stay pending and are published again
+	// after it, so nothing overtakes a failed record
+	sent := 0
+	var failed error
+	for i := range records {
+		if errs[i] == nil {
+			_, errs[i] = futures[i].Wait(publishCtx)
+		}
+		if errs[i] != nil {
+			failed = errs[i]
+			break
+		}
+		sent++
+	}
+
+	// Record the outcome even when stopping, so sent records are not published again
+	return sent, r.settle(context.WithoutCancel(ctx), records, sent, failed)
+}
+
+// claim loads the oldest pending records and claims them for claimLease. It returns none
+// when another relay holds a claim on them.
+func (r *relay) claim(ctx context.Context) ([]Record, error) {
+	var records []Record
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("status = ?", StatusPending).
+			Order("id").
+			Limit(r.cfg.BatchSize).
+			Find(&records).Error; err != nil {
+			return fmt.Errorf("failed to load pending outbox records: %w", err)
+		}
+
+		now := time.Now()
+		ids := make([]uint64, len(records))
+		for i, record := range records {
+			if record.ClaimedUntil != nil && record.ClaimedUntil.After(now) {
+				records = nil
+				return nil
+			}
+			ids[i] = record.ID
+		}
+		if len(ids) == 0 {
+			return nil
+		}
+
+		if err := tx.Model(&Record{}).Where("id IN ?", ids).Update("claimed_until", now.Add(claimLease)).Error; err != nil {
+			return fmt.Errorf("failed to claim outbox records: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+// settle marks the first sent records as sent, records the failure of the next one if any,
+// and releases the claim on the rest
+func (r *relay) settle(ctx context.Context, records []Record, sent int, failed error) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if sent > 0 {
+			ids := make([]uint64, sent)
+			for i := range ids {
+				ids[i] = records[i].ID
+			}
+			if err := tx.Model(&Record{}).Where("id IN ?", ids).Updates(map[string]interface{}{
+				"status":        StatusSent,
+				"sent_at":       time.Now(),
+				"claimed_until": nil,
+			}).Error; err != nil {
+				return fmt.Errorf("failed to mark outbox records sent: %w", err)
+			}
+		}
+		if sent == len(records) {
+			return nil
+		}
+
+		if err := r.markFailed(tx, &records[sent], failed); err != nil {
+			return err
+		}
+
+		rest := make([]uint64, 0, len(records)-sent)
+		for _, record := range records[sent:] {
+			rest = append(rest, record.ID)
+		}
+		if err := tx.Model(&Record{}).Where("id IN ?", rest).Update("claimed_until", nil).Error; err != nil {
+			return fmt.Errorf("failed to release outbox records: %w", err)
+		}
+		return nil
+	})
+}
+
+// markFailed records a failed publish attempt, giving up after MaxAttempts
+func (r *relay) markFailed(tx *gorm.DB, record *Record, cause error) error {
+	record.Attempts++
+	status := StatusPending
+	if r.cfg.MaxAttempts > 0 && record.Attempts >= r.cfg.MaxAttempts {
+		status = StatusFailed
+		logger.Errorf("Outbox record %d for topic %s failed %d times, giving up: %v", record.ID, record.Topic, record.Attempts, cause)
+	} else {
+		logger.Warnf("Outbox record %d for topic %s failed (attempt %d): %v", record.ID, record.Topic, record.Attempts, cause)
+	}
+
+	lastError := cause.Error()
+	if len(lastError) > 1024 {
+		lastError = lastError[:1024]
+	}
+
+	if err := tx.Model(record).Updates(map[string]interface{}{
+		"status":     status,
+		"attempts":   record.Attempts,
+		"last_error": lastError,
+	}).Error; err != nil {
+		return fmt.Errorf("failed to update outbox record %d: %w", record.ID, err)
+	}
+	return nil
+}
+
+// cleanup deletes sent records older than the retention period
+func (r *relay) cleanup(ctx context.Context) {
+	if r.cfg.Retention <= 0 {
+		return
+	}
+
+	res := r.db.WithContext(ctx).
+		Where("status = ? AND sent_at < ?", StatusSent, time.Now().Add(-r.cfg.Retention)).
+		Delete(&Record{})
+	if res.Error != nil {
+		logger.Errorf("Outbox cleanup failed: %v", res.Error)
+		return
+	}
+	if res.RowsAffected > 0 {
+		logger.Infof("Outbox cleanup deleted %d sent records", res.RowsAffected)
+	}
+}
@@ -0,0 +1,50 @@
+package schemaregistry
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/hamba/avro/v2"
+)
+
+// Avro payloads are encoded with github.com/hamba/avro. Go values map onto Avro types as that
+// package defines: records to structs, with fields matched by their avro tag, or to
+// map[string]any; enums to strings; unions with null to pointers; arrays to slices; maps to
+// map[string]T; fixed to byte arrays; and logical types such as timestamp-millis, date and
+// decimal to time.Time, time.Time and *big.Rat. Decoding into an interface{} yields the
+// generic representation.
+
+// parseAvroSchema parses an Avro schema in its JSON form. Each schema gets its own cache of
+// named types, so versions of a record registered under the same name do not mix.
+func parseAvroSchema(schema string) (avro.Schema, error) {
+	parsed, err := avro.ParseWithCache(schema, "", &avro.SchemaCache{})
+	if err != nil {
+		return nil, fmt.Errorf("invalid Avro schema: %w", err)
+	}
+	return parsed, nil
+}
+
+// resolveAvroSchema returns the schema that reads data written with writer as reader, filling
+// fields added since with their defaults and skipping removed ones
+func resolveAvroSchema(reader, writer avro.Schema) (avro.Schema, error) {
+	if reader.Fingerprint() == writer.Fingerprint() {
+		return reader, nil
+	}
+	resolved, err := avro.NewSchemaCompatibility().Resolve(reader, writer)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read data written with schema %s: %w", writer.String(), err)
+	}
+	return resolved, nil
+}
+
+// decodeAvro reads payload into v. Unlike avro.Unmarshal, which treats running out of data
+// as the end of the value, it fails on a truncated payload.
+func decodeAvro(schema avro.Schema, payload []byte, v any) error {
+	r := avro.NewReader(nil, 0).Reset(payload)
+	r.ReadVal(schema, v)
+	if errors.Is(r.Error, io.EOF) {
+		return fmt.Errorf("truncated payload of %d bytes", len(payload))
+	}
+	return r.Error
+}
@@ -0,0 +1,350 @@
+package schemaregistry
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"yourapp/pkg/config"
+
+	"github.com/hamba/avro/v2"
+)
+
+// Schema types as named by the registry
+const (
+	TypeAvro     = "AVRO"
+	TypeProtobuf = "PROTOBUF"
+	TypeJSON     = "JSON"
+)
+
+// Registry error codes used by the client
+const (
+	codeSubjectNotFound = 40401
+	codeVersionNotFound = 40402
+	codeSchemaNotFound  = 40403
+)
+
+const contentType = "application/vnd.schemaregistry.v1+json"
+
+var client *Client
+
+// Init creates the package client for the configured registry
+func Init(ctx context.Context, cfg config.SchemaRegistryConfig) error {
+	c, err := NewClient(cfg)
+	if err != nil {
+		return err
+	}
+
+	// Test the connection
+	if err := c.Health(ctx); err != nil {
+		return fmt.Errorf("failed to reach schema registry: %w", err)
+	}
+
+	client = c
+	return nil
+}
+
+// GetClient returns the package client
+func GetClient() *Client {
+	return client
+}
+
+// Health checks that the package client can reach the registry
+func Health(ctx context.Context) error {
+	if client == nil {
+		return fmt.Errorf("schema registry client not initialized")
+	}
+	return client.Health(ctx)
+}
+
+// Reference points at another registered schema imported by a schema
+type Reference struct {
+	Name    string `json:"name"`
+	Subject string `json:"subject"`
+	Version int    `json:"version"`
+}
+
+// Schema is a registered schema
+type Schema struct {
+	ID      int    `json:"id,omitempty"`
+	Subject string `json:"subject,omitempty"`
+	Version int    `json:"version,omitempty"`
+	// Type is one of TypeAvro, TypeProtobuf or TypeJSON; the registry treats empty as Avro
+	Type       string      `json:"schemaType,omitempty"`
+	Schema     string      `json:"schema"`
+	References []Reference `json:"references,omitempty"`
+}
+
+// Error is an error response from the registry
+type Error struct {
+	StatusCode int    `json:"-"`
+	Code       int    `json:"error_code"`
+	Message    string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("schema registry error %d (HTTP %d): %s", e.Code, e.StatusCode, e.Message)
+}
+
+// IncompatibleSchemaError is returned when registering a schema that is not compatible
+// with the subject's compatibility setting
+type IncompatibleSchemaError struct {
+	Subject  string
+	Messages []string
+}
+
+func (e *IncompatibleSchemaError) Error() string {
+	if len(e.Messages) == 0 {
+		return fmt.Sprintf("schema is incompatible with subject %s", e.Subject)
+	}
+	return fmt.Sprintf("schema is incompatible with subject %s: %s", e.Subject, strings.Join(e.Messages, "; "))
+}
+
+// IsNotFound reports whether err is a registry error for a missing subject, version or schema
+func IsNotFound(err error) bool {
+	var e *Error
+	if !errors.As(err, &e) {
+		return false
+	}
+	return e.Code == codeSubjectNotFound || e.Code == codeVersionNotFound || e.Code == codeSchemaNotFound
+}
+
+// Client talks to a Confluent-compatible Schema Registry over its REST API.
+// Schemas looked up by ID and IDs of registered schemas are cached, as both are immutable.
+type Client struct {
+	baseURL      string
+	username     string
+	password     string
+	timeout      time.Duration
+	autoRegister bool
+	http         *http.Client
+
+	mu     sync.RWMutex
+	byID   map[int]*Schema
+	ids    map[string]int
+	parsed map[int]avro.Schema
+}
+
+// NewClient creates a client for the configured registry
+func NewClient(cfg config.SchemaRegistryConfig) (*Client, error) {
+	if cfg.URL == "" {
+		return nil, fmt.Errorf("schema registry URL not configured")
+	}
+	if _, err := url.Parse(cfg.URL); err != nil {
+		return nil, fmt.Errorf("invalid schema registry URL: %w", err)
+	}
+
+	return &Client{
+		baseURL:      strings.TrimRight(cfg.URL, "/"),
+		username:     cfg.Username,
+		password:     cfg.Password,
+		timeout:      cfg.Timeout,
+		autoRegister: cfg.AutoRegister,
+		http:         &http.Client{Timeout: cfg.Timeout},
+		byID:         make(map[int]*Schema),
+		ids:          make(map[string]int),
+		parsed:       make(map[int]avro.Schema),
+	}, nil
+}
+
+// Health checks that the registry answers
+func (c *Client) Health(ctx context.Context) error {
+	return c.do(ctx, http.MethodGet, "/subjects", nil, nil)
+}
+
+// SchemaByID returns the schema with the given ID
+func (c *Client) SchemaByID(ctx context.Context, id int) (*Schema, error) {
+	c.mu.RLock()
+	s, ok := c.byID[id]
+	c.mu.RUnlock()
+	if ok {
+		return s, nil
+	}
+
+	s = &Schema{}
+	if err := c.do(ctx, http.MethodGet, fmt.Sprintf("/schemas/ids/%d", id), nil, s); err != nil {
+		return nil, fmt.Errorf("failed to get schema %d: %w", id, err)
+	}
+	s.ID = id
+
+	c.mu.Lock()
+	c.byID[id] = s
+	c.mu.Unlock()
+	return s, nil
+}
+
+// LatestSchema returns the latest version registered under a subject
+func (c *Client) LatestSchema(ctx context.Context, subject string) (*Schema, error) {
+	s := &Schema{}
+	if err := c.do(ctx, http.MethodGet, "/subjects/"+url.PathEscape(subject)+"/versions/latest", nil, s); err != nil {
+		return nil, fmt.Errorf("failed to get latest schema of %s: %w", subject, err)
+	}
+	return s, nil
+}
+
+// Lookup returns the ID under which schema is registered in subject
+func (c *Client) Lookup(ctx context.Context, subject string, schema Schema) (int, error) {
+	if id, ok := c.cachedID(subject, schema); ok {
+		return id, nil
+	}
+
+	var res Schema
+	if err := c.do(ctx, http.MethodPost, "/subjects/"+url.PathEscape(subject), schema, &res); err != nil {
+		return 0, fmt.Errorf("failed to look up schema in %s: %w", subject, err)
+	}
+	c.cacheID(subject, schema, res.ID)
+	return res.ID, nil
+}
+
+// CheckCompatibility tests schema against the latest version of subject. A subject without
+// versions accepts any schema.
+func (c *Client) CheckCompatibility(ctx context.Context, subject string, schema Schema) error {
+	var res struct {
+		IsCompatible bool     `json:"is_compatible"`
+		Messages     []string `json:"messages"`
+	}
+	path := "/compatibility/subjects/" + url.PathEscape(subject) + "/versions/latest?verbose=true"
+	if err := c.do(ctx, http.MethodPost, path, schema, &res); err != nil {
+		if IsNotFound(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to check compatibility with %s: %w", subject, err)
+	}
+	if !res.IsCompatible {
+		return &IncompatibleSchemaError{Subject: subject, Messages: res.Messages}
+	}
+	return nil
+}
+
+// Register registers schema under subject after checking it is compatible, and returns its
+// ID. Registering a schema that already exists returns the existing ID.
+func (c *Client) Register(ctx context.Context, subject string, schema Schema) (int, error) {
+	if id, ok := c.cachedID(subject, schema); ok {
+		return id, nil
+	}
+
+	if err := c.CheckCompatibility(ctx, subject, schema); err != nil {
+		return 0, err
+	}
+
+	var res struct {
+		ID int `json:"id"`
+	}
+	if err := c.do(ctx, http.MethodPost, "/subjects/"+url.PathEscape(subject)+"/versions", schema, &res); err != nil {
+		return 0, fmt.Errorf("failed to register schema in %s: %w", subject, err)
+	}
+	c.cacheID(subject, schema, res.ID)
+	return res.ID, nil
+}
+
+// schemaID returns the ID of schema in subject, registering it when auto registration is on
+func (c *Client) schemaID(ctx context.Context, subject string, schema Schema) (int, error) {
+	if c.autoRegister {
+		return c.Register(ctx, subject, schema)
+	}
+	return c.Lookup(ctx, subject, schema)
+}
+
+// avroSchemaByID returns the parsed Avro schema with the given ID
+func (c *Client) avroSchemaByID(ctx context.Context, id int) (avro.Schema, error) {
+	c.mu.RLock()
+	parsed, ok := c.parsed[id]
+	c.mu.RUnlock()
+	if ok {
+		return parsed, nil
+	}
+
+	s, err := c.SchemaByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if s.Type != "" && s.Type != TypeAvro {
+		return nil, fmt.Errorf("schema %d is a %s schema, not Avro", id, s.Type)
+	}
+	if parsed, err = parseAvroSchema(s.Schema); err != nil {
+		return nil, fmt.Errorf("schema %d: %w", id, err)
+	}
+
+	c.mu.Lock()
+	c.parsed[id] = parsed
+	c.mu.Unlock()
+	return parsed, nil
+}
+
+func (c *Client) cachedID(subject string, schema Schema) (int, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	id, ok := c.ids[cacheKey(subject, schema)]
+	return id, ok
+}
+
+func (c *Client) cacheID(subject string, schema Schema, id int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.ids[cacheKey(subject, schema)] = id
+}
+
+func cacheKey(subject string, schema Schema) string {
+	return subject + "\x00" + schema.Type + "\x00" + schema.Schema
+}
+
+// context bounds a serializer call, which has no context of its own, by the client timeout
+func (c *Client) context() (context.Context, context.CancelFunc) {
+	if c.timeout > 0 {
+		return context.WithTimeout(context.Background(), c.timeout)
+	}
+	return context.WithCancel(context.Background())
+}
+
+// do sends a request and decodes the JSON response into out when it is not nil
+func (c *Client) do(ctx context.Context, method, path string, in, out any) error {
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", contentType)
+	if in != nil {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if c.username != "" {
+		req.SetBasicAuth(c.username, c.password)
+	}
+
+	res, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode >= 300 {
+		e := &Error{StatusCode: res.StatusCode}
+		data, _ := io.ReadAll(res.Body)
+		if json.Unmarshal(data, e) != nil || e.Message == "" {
+			e.Message = strings.TrimSpace(string(data))
+		}
+		return e
+	}
+
+	if out == nil {
+		_, _ = io.Copy(io.Discard, res.Body)
+		return nil
+	}
+	return json.NewDecoder(res.Body).Decode(out)
+}
@@ -0,0 +1,209 @@
+package schemaregistry
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+
+	"yourapp/pkg/messaging/kafka"
+
+	"github.com/hamba/avro/v2"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// TopicSubject returns the subject of a topic's value schema under the registry's default
+// topic name strategy
+func TopicSubject(topic string) string {
+	return topic + "-value"
+}
+
+// TopicKeySubject returns the subject of a topic's key schema
+func TopicKeySubject(topic string) string {
+	return topic + "-key"
+}
+
+// AvroSerde is a kafka.Codec writing Avro payloads in the Schema Registry wire format.
+// Payloads are read through the schema they were written with, looked up by the ID in the
+// header, resolved against the serde's schema, so data written with older or newer
+// compatible schemas can be read.
+type AvroSerde struct {
+	client  *Client
+	subject string
+	schema  avro.Schema
+	source  string
+
+	mu       sync.RWMutex
+	resolved map[int]avro.Schema
+}
+
+// NewAvroSerde creates an Avro serde writing with schema under subject
+func NewAvroSerde(c *Client, subject, schema string) (*AvroSerde, error) {
+	parsed, err := parseAvroSchema(schema)
+	if err != nil {
+		return nil, err
+	}
+	return &AvroSerde{client: c, subject: subject, schema: parsed, source: schema, resolved: make(map[int]avro.Schema)}, nil
+}
+
+// ContentType implements kafka.Codec
+func (s *AvroSerde) ContentType() string { return "application/vnd.confluent.avro" }
+
+// Marshal implements kafka.Codec
+func (s *AvroSerde) Marshal(v any) ([]byte, error) {
+	id, err := s.schemaID()
+	if err != nil {
+		return nil, err
+	}
+
+	payload, err := avro.Marshal(s.schema, v)
+	if err != nil {
+		return nil, fmt.Errorf("avro: %w", err)
+	}
+	return append(appendHeader(nil, id), payload...), nil
+}
+
+// Unmarshal implements kafka.Codec
+func (s *AvroSerde) Unmarshal(data []byte, v any) error {
+	id, payload, err := parseHeader(data)
+	if err != nil {
+		return err
+	}
+
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return fmt.Errorf("avro: cannot decode into %T", v)
+	}
+
+	schema, err := s.readerSchema(id)
+	if err != nil {
+		return err
+	}
+
+	if err := decodeAvro(schema, payload, v); err != nil {
+		return fmt.Errorf("avro: %w", err)
+	}
+	return nil
+}
+
+// readerSchema returns the schema reading payloads written with the schema with the given ID
+func (s *AvroSerde) readerSchema(id int) (avro.Schema, error) {
+	s.mu.RLock()
+	schema, ok := s.resolved[id]
+	s.mu.RUnlock()
+	if ok {
+		return schema, nil
+	}
+
+	ctx, cancel := s.client.context()
+	defer cancel()
+	writer, err := s.client.avroSchemaByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if schema, err = resolveAvroSchema(s.schema, writer); err != nil {
+		return nil, fmt.Errorf("avro: schema %d: %w", id, err)
+	}
+
+	s.mu.Lock()
+	s.resolved[id] = schema
+	s.mu.Unlock()
+	return schema, nil
+}
+
+// schemaID returns the ID of the writer schema; the client caches it after the first call
+func (s *AvroSerde) schemaID() (int, error) {
+	ctx, cancel := s.client.context()
+	defer cancel()
+	return s.client.schemaID(ctx, s.subject, Schema{Type: TypeAvro, Schema: s.source})
+}
+
+// ProtobufSerde is a kafka.Codec writing Protobuf payloads in the Schema Registry wire
+// format: the header is followed by the index path of the message type within its schema
+// file and the serialized message.
+type ProtobufSerde struct {
+	client  *Client
+	subject string
+	schema  Schema
+
+	mu       sync.Mutex
+	latestID int
+}
+
+// NewProtobufSerde creates a Protobuf serde for subject. The registry identifies Protobuf
+// schemas by their .proto source, so schema must be the content of the file that defines the
+// published messages, with imports given as references. When schema is empty the latest
+// version registered under subject is used.
+func NewProtobufSerde(c *Client, subject, schema string, references ...Reference) *ProtobufSerde {
+	return &ProtobufSerde{
+		client:  c,
+		subject: subject,
+		schema:  Schema{Type: TypeProtobuf, Schema: schema, References: references},
+	}
+}
+
+// ContentType implements kafka.Codec
+func (s *ProtobufSerde) ContentType() string { return "application/vnd.confluent.protobuf" }
+
+// Marshal implements kafka.Codec
+func (s *ProtobufSerde) Marshal(v any) ([]byte, error) {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return nil, fmt.Errorf("protobuf: %T is not a proto.Message", v)
+	}
+
+	id, err := s.schemaID()
+	if err != nil {
+		return nil, err
+	}
+
+	b := appendMessageIndexes(appendHeader(nil, id), messageIndexes(m.ProtoReflect().Descriptor()))
+	return proto.MarshalOptions{}.MarshalAppend(b, m)
+}
+
+// Unmarshal implements kafka.Codec
+func (s *ProtobufSerde) Unmarshal(data []byte, v any) error {
+	_, payload, err := parseHeader(data)
+	if err != nil {
+		return err
+	}
+	if _, payload, err = parseMessageIndexes(payload); err != nil {
+		return err
+	}
+	return kafka.ProtobufCodec.Unmarshal(payload, v)
+}
+
+// schemaID returns the ID of the writer schema. The latest version is resolved only once,
+// so a serde keeps writing with the same schema for its lifetime.
+func (s *ProtobufSerde) schemaID() (int, error) {
+	ctx, cancel := s.client.context()
+	defer cancel()
+
+	if s.schema.Schema != "" {
+		return s.client.schemaID(ctx, s.subject, s.schema)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.latestID == 0 {
+		latest, err := s.client.LatestSchema(ctx, s.subject)
+		if err != nil {
+			return 0, err
+		}
+		s.latestID = latest.ID
+	}
+	return s.latestID, nil
+}
+
+// messageIndexes returns the path of a message type within its file: the index of the
+// top-level message followed by the indexes of the nested messages down to md
+func messageIndexes(md protoreflect.MessageDescriptor) []int {
+	var indexes []int
+	for d := protoreflect.Descriptor(md); d != nil; d = d.Parent() {
+		if _, ok := d.(protoreflect.MessageDescriptor); !ok {
+			break
+		}
+		indexes = append([]int{d.Index()}, indexes...)
+	}
+	return indexes
+}
@@ -0,0 +1,414 @@
+package schemaregistry
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"yourapp/pkg/config"
+
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// fakeRegistry is an in-memory stand-in for the Schema Registry REST API
+type fakeRegistry struct {
+	mu           sync.Mutex
+	schemas      []Schema         // by ID - 1
+	subjects     map[string][]int // IDs per subject in version order
+	incompatible map[string]bool  // subjects whose compatibility check fails
+	requests     map[string]int   // request count per method and path
+	srv          *httptest.Server
+}
+
+func newFakeRegistry(t *testing.T) *fakeRegistry {
+	r := &fakeRegistry{
+		subjects:     map[string][]int{},
+		incompatible: map[string]bool{},
+		requests:     map[string]int{},
+	}
+	r.srv = httptest.NewServer(http.HandlerFunc(r.serve))
+	t.Cleanup(r.srv.Close)
+	return r
+}
+
+func (r *fakeRegistry) client(t *testing.T, autoRegister bool) *Client {
+	c, err := NewClient(config.SchemaRegistryConfig{URL: r.srv.URL, Timeout: 5 * time.Second, AutoRegister: autoRegister})
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	return c
+}
+
+func (r *fakeRegistry) count(method, path string) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.requests[method+" "+path]
+}
+
+func (r *fakeRegistry) serve(w http.ResponseWriter, req *http.Request) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.requests[req.Method+" "+req.URL.Path]++
+
+	notFound := func(code int, msg string) {
+		w.WriteHeader(http.StatusNotFound)
+		_ = json.NewEncoder(w).Encode(map[string]any{"error_code": code, "message": msg})
+	}
+	reply := func(v any) { _ = json.NewEncoder(w).Encode(v) }
+
+	var in Schema
+	if req.Method == http.MethodPost {
+		if err := json.NewDecoder(req.Body).Decode(&in); err != nil {
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			return
+		}
+	}
+	find := func(subject string) int {
+		for _, id := range r.subjects[subject] {
+			s := r.schemas[id-1]
+			if s.Schema == in.Schema && s.Type == in.Type {
+				return id
+			}
+		}
+		return 0
+	}
+
+	parts := strings.Split(strings.Trim(req.URL.Path, "/"), "/")
+	switch {
+	case req.Method == http.MethodGet && req.URL.Path == "/subjects":
+		reply([]string{})
+	case req.Method == http.MethodGet && len(parts) == 3 && parts[0] == "schemas" && parts[1] == "ids":
+		id, _ := strconv.Atoi(parts[2])
+		if id < 1 || id > len(r.schemas) {
+			notFound(codeSchemaNotFound, "Schema not found")
+			return
+		}
+		s := r.schemas[id-1]
+		reply(Schema{Type: s.Type, Schema: s.Schema})
+	case req.Method == http.MethodGet && len(parts) == 4 && parts[0] == "subjects" && parts[3] == "latest":
+		ids := r.subjects[parts[1]]
+		if len(ids) == 0 {
+			notFound(codeSubjectNotFound, "Subject not found")
+			return
+		}
+		s := r.schemas[ids[len(ids)-1]-1]
+		reply(Schema{ID: ids[len(ids)-1], Subject: parts[1], Version: len(ids), Type: s.Type, Schema: s.Schema})
+	case req.Method == http.MethodPost && len(parts) == 2 && parts[0] == "subjects":
+		id := find(parts[1])
+		if id == 0 {
+			notFound(codeSchemaNotFound, "Schema not found")
+			return
+		}
+		reply(Schema{ID: id, Subject: parts[1]})
+	case req.Method == http.MethodPost && len(parts) == 3 && parts[0] == "subjects" && parts[2] == "versions":
+		id := find(parts[1])
+		if id == 0 {
+			r.schemas = append(r.schemas, in)
+			id = len(r.schemas)
+			r.subjects[parts[1]] = append(r.subjects[parts[1]], id)
+		}
+		reply(map[string]int{"id": id})
+	case req.Method == http.MethodPost && len(parts) == 5 && parts[0] == "compatibility":
+		if len(r.subjects[parts[2]]) == 0 {
+			notFound(codeSubjectNotFound, "Subject not found")
+			return
+		}
+		if r.incompatible[parts[2]] {
+			reply(map[string]any{"is_compatible": false, "messages": []string{"field removed"}})
+			return
+		}
+		reply(map[string]any{"is_compatible": true})
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+const userV1 = `{"type": "record", "name": "User", "namespace": "test", "fields": [
+	{"name": "id", "type": "long"},
+	{"name": "email", "type": ["null", "string"], "default": null},
+	{"name": "created", "type": {"type": "long", "logicalType": "timestamp-millis"}},
+	{"name": "birthday", "type": {"type": "int", "logicalType": "date"}},
+	{"name": "balance", "type": {"type": "bytes", "logicalType": "decimal", "precision": 10, "scale": 2}},
+	{"name": "kind", "type": {"type": "enum", "name": "Kind", "symbols": ["PERSON", "COMPANY"]}},
+	{"name": "tags", "type": {"type": "array", "items": "string"}},
+	{"name": "limits", "type": {"type": "map", "values": "int"}},
+	{"name": "legacy", "type": "string", "default": ""}
+]}`
+
+// userV2 adds country with a default and removes legacy
+const userV2 = `{"type": "record", "name": "User", "namespace": "test", "fields": [
+	{"name": "id", "type": "long"},
+	{"name": "email", "type": ["null", "string"], "default": null},
+	{"name": "created", "type": {"type": "long", "logicalType": "timestamp-millis"}},
+	{"name": "birthday", "type": {"type": "int", "logicalType": "date"}},
+	{"name": "balance", "type": {"type": "bytes", "logicalType": "decimal", "precision": 10, "scale": 2}},
+	{"name": "kind", "type": {"type": "enum", "name": "Kind", "symbols": ["PERSON", "COMPANY"]}},
+	{"name": "tags", "type": {"type": "array", "items": "string"}},
+	{"name": "limits", "type": {"type": "map", "values": "int"}},
+	{"name": "country", "type": "string", "default": "NL"}
+]}`
+
+type user struct {
+	ID       int64          `avro:"id"`
+	Email    *string        `avro:"email"`
+	Created  time.Time      `avro:"created"`
+	Birthday time.Time      `avro:"birthday"`
+	Balance  *big.Rat       `avro:"balance"`
+	Kind     string         `avro:"kind"`
+	Tags     []string       `avro:"tags"`
+	Limits   map[string]int `avro:"limits"`
+	Legacy   string         `avro:"legacy"`
+	Country  string         `avro:"country"`
+}
+
+func newUser() user {
+	email := "ada@example.com"
+	return user{
+		ID:       42,
+		Email:    &email,
+		Created:  time.UnixMilli(1700000000123).UTC(),
+		Birthday: time.Date(1815, 12, 10, 0, 0, 0, 0, time.UTC),
+		Balance:  big.NewRat(123456, 100),
+		Kind:     "COMPANY",
+		Tags:     []string{"a", "b"},
+		Limits:   map[string]int{"daily": 10},
+		Legacy:   "old",
+	}
+}
+
+func newAvroSerde(t *testing.T, c *Client, subject, schema string) *AvroSerde {
+	s, err := NewAvroSerde(c, subject, schema)
+	if err != nil {
+		t.Fatalf("NewAvroSerde: %v", err)
+	}
+	return s
+}
+
+func TestAvroSerdeRoundTrip(t *testing.T) {
+	reg := newFakeRegistry(t)
+	serde := newAvroSerde(t, reg.client(t, true), "users-value", userV1)
+
+	tests := []struct {
+		name string
+		in   func() user
+	}{
+		{"all fields", newUser},
+		{"null union", func() user { u := newUser(); u.Email = nil; return u }},
+		{"empty collections", func() user { u := newUser(); u.Tags, u.Limits = []string{}, map[string]int{}; return u }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			in := tt.in()
+			data, err := serde.Marshal(in)
+			if err != nil {
+				t.Fatalf("Marshal: %v", err)
+			}
+			if id, err := SchemaID(data); err != nil || id != 1 {
+				t.Fatalf("SchemaID = %d, %v; want 1", id, err)
+			}
+
+			var out user
+			if err := serde.Unmarshal(data, &out); err != nil {
+				t.Fatalf("Unmarshal: %v", err)
+			}
+			if out.Balance.Cmp(in.Balance) != 0 {
+				t.Errorf("balance = %s, want %s", out.Balance, in.Balance)
+			}
+			out.Balance, in.Balance = nil, nil
+			if len(in.Tags) == 0 && len(out.Tags) == 0 {
+				out.Tags, in.Tags = nil, nil
+			}
+			if len(in.Limits) == 0 && len(out.Limits) == 0 {
+				out.Limits, in.Limits = nil, nil
+			}
+			if !reflect.DeepEqual(out, in) {
+				t.Errorf("round trip\n got %+v\nwant %+v", out, in)
+			}
+		})
+	}
+
+	if n := reg.count(http.MethodPost, "/subjects/users-value/versions"); n != 1 {
+		t.Errorf("registered %d times, want the ID cached after the first", n)
+	}
+}
+
+func TestAvroSerdeGeneric(t *testing.T) {
+	reg := newFakeRegistry(t)
+	serde := newAvroSerde(t, reg.client(t, true), "users-value", userV1)
+
+	data, err := serde.Marshal(newUser())
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	var out any
+	if err := serde.Unmarshal(data, &out); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	m, ok := out.(map[string]any)
+	if !ok {
+		t.Fatalf("decoded %T, want map[string]any", out)
+	}
+	if m["id"] != int64(42) || m["email"] != "ada@example.com" || m["kind"] != "COMPANY" {
+		t.Errorf("decoded %v", m)
+	}
+}
+
+func TestAvroSerdeSchemaEvolution(t *testing.T) {
+	reg := newFakeRegistry(t)
+	c := reg.client(t, true)
+	v1 := newAvroSerde(t, c, "users-value", userV1)
+	v2 := newAvroSerde(t, c, "users-value", userV2)
+
+	old, err := v1.Marshal(newUser())
+	if err != nil {
+		t.Fatalf("Marshal v1: %v", err)
+	}
+	var fromOld user
+	if err := v2.Unmarshal(old, &fromOld); err != nil {
+		t.Fatalf("v2 reading v1 data: %v", err)
+	}
+	if fromOld.Country != "NL" {
+		t.Errorf("country = %q, want the default NL", fromOld.Country)
+	}
+	if fromOld.Legacy != "" {
+		t.Errorf("legacy = %q, want it skipped", fromOld.Legacy)
+	}
+	if fromOld.ID != 42 || *fromOld.Email != "ada@example.com" {
+		t.Errorf("v2 reading v1 data = %+v", fromOld)
+	}
+
+	u := newUser()
+	u.Country = "BE"
+	current, err := v2.Marshal(u)
+	if err != nil {
+		t.Fatalf("Marshal v2: %v", err)
+	}
+	var fromNew user
+	if err := v1.Unmarshal(current, &fromNew); err != nil {
+		t.Fatalf("v1 reading v2 data: %v", err)
+	}
+	if fromNew.Legacy != "" || fromNew.Country != "" || fromNew.ID != 42 {
+		t.Errorf("v1 reading v2 data = %+v", fromNew)
+	}
+
+	if n := reg.count(http.MethodGet, "/schemas/ids/1"); n != 1 {
+		t.Errorf("fetched writer schema %d times, want it cached", n)
+	}
+}
+
+func TestAvroSerdeIncompatibleSchema(t *testing.T) {
+	reg := newFakeRegistry(t)
+	c := reg.client(t, true)
+	if _, err := newAvroSerde(t, c, "users-value", userV1).Marshal(newUser()); err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+
+	reg.incompatible["users-value"] = true
+	_, err := newAvroSerde(t, c, "users-value", userV2).Marshal(newUser())
+	var incompatible *IncompatibleSchemaError
+	if !errors.As(err, &incompatible) {
+		t.Fatalf("Marshal = %v, want an IncompatibleSchemaError", err)
+	}
+}
+
+func TestAvroSerdeLookupOnly(t *testing.T) {
+	reg := newFakeRegistry(t)
+	serde := newAvroSerde(t, reg.client(t, false), "users-value", userV1)
+
+	_, err := serde.Marshal(newUser())
+	if !IsNotFound(err) {
+		t.Fatalf("Marshal with an unregistered schema = %v, want not found", err)
+	}
+	if n := reg.count(http.MethodPost, "/subjects/users-value/versions"); n != 0 {
+		t.Errorf("registered without auto_register")
+	}
+}
+
+func TestProtobufSerdeRoundTrip(t *testing.T) {
+	reg := newFakeRegistry(t)
+	serde := NewProtobufSerde(reg.client(t, true), "names-value", `syntax = "proto3"; message StringValue { string value = 1; }`)
+
+	data, err := serde.Marshal(wrapperspb.String("hello"))
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	out := &wrapperspb.StringValue{}
+	if err := serde.Unmarshal(data, out); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	if out.GetValue() != "hello" {
+		t.Errorf("value = %q, want hello", out.GetValue())
+	}
+}
+
+func TestMessageIndexes(t *testing.T) {
+	for _, indexes := range [][]int{{0}, {1}, {0, 2}, {3, 1, 4}} {
+		data := append(appendMessageIndexes(nil, indexes), 0xff)
+		got, rest, err := parseMessageIndexes(data)
+		if err != nil {
+			t.Fatalf("parseMessageIndexes(%v): %v", indexes, err)
+		}
+		if !reflect.DeepEqual(got, indexes) || !reflect.DeepEqual(rest, []byte{0xff}) {
+			t.Errorf("parseMessageIndexes(%v) = %v, %v", indexes, got, rest)
+		}
+	}
+}
+
+func TestMalformedFrames(t *testing.T) {
+	reg := newFakeRegistry(t)
+	c := reg.client(t, true)
+	avroSerde := newAvroSerde(t, c, "users-value", userV1)
+	valid, err := avroSerde.Marshal(newUser())
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	protoSerde := NewProtobufSerde(c, "names-value", `syntax = "proto3";`)
+	header := appendHeader(nil, 1)
+
+	tests := []struct {
+		name  string
+		serde interface{ Unmarshal([]byte, any) error }
+		data  []byte
+	}{
+		{"avro empty", avroSerde, nil},
+		{"avro short header", avroSerde, []byte{0, 0, 1}},
+		{"avro wrong magic byte", avroSerde, append([]byte{1}, valid[1:]...)},
+		{"avro unknown schema", avroSerde, append(appendHeader(nil, 99), valid[5:]...)},
+		{"avro truncated payload", avroSerde, valid[:len(valid)-4]},
+		{"avro header only", avroSerde, header},
+		{"protobuf header only", protoSerde, header},
+		{"protobuf negative count", protoSerde, append(header, 0x01)},
+		{"protobuf huge count", protoSerde, binary.AppendVarint(append([]byte(nil), header...), 1<<60)},
+		{"protobuf truncated indexes", protoSerde, binary.AppendVarint(append([]byte(nil), header...), 3)},
+		{"protobuf negative index", protoSerde, binary.AppendVarint(binary.AppendVarint(append([]byte(nil), header...), 1), -1)},
+		{"protobuf unterminated varint", protoSerde, append(header, 0x80)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var target any = &user{}
+			if tt.serde == protoSerde {
+				target = &wrapperspb.StringValue{}
+			}
+			if err := tt.serde.Unmarshal(tt.data, target); err == nil {
+				t.Errorf("Unmarshal(% x) succeeded, want an error", tt.data)
+			}
+		})
+	}
+}
+
+func TestProtobufSerdeRejectsOtherTypes(t *testing.T) {
+	reg := newFakeRegistry(t)
+	serde := NewProtobufSerde(reg.client(t, true), "names-value", `syntax = "proto3";`)
+	if _, err := serde.Marshal("not a message"); err == nil {
+		t.Error("Marshal of a string succeeded")
+	}
+}
@@ -0,0 +1,74 @@
+package schemaregistry
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// magicByte starts every payload in the Schema Registry wire format
+const magicByte = 0
+
+// ErrNotWireFormat is returned when a payload does not start with the wire format header
+var ErrNotWireFormat = errors.New("payload is not in Schema Registry wire format")
+
+// appendHeader appends the wire format header: the magic byte and the big-endian schema ID
+func appendHeader(b []byte, id int) []byte {
+	b = append(b, magicByte)
+	return binary.BigEndian.AppendUint32(b, uint32(id))
+}
+
+// parseHeader returns the schema ID of a wire format payload and the data following it
+func parseHeader(data []byte) (int, []byte, error) {
+	if len(data) < 5 || data[0] != magicByte {
+		return 0, nil, ErrNotWireFormat
+	}
+	return int(binary.BigEndian.Uint32(data[1:5])), data[5:], nil
+}
+
+// SchemaID returns the ID of the schema a wire format payload was written with
+func SchemaID(data []byte) (int, error) {
+	id, _, err := parseHeader(data)
+	return id, err
+}
+
+// appendMessageIndexes appends the path of a Protobuf message within its schema file.
+// The common case of the first top-level message is written as a single zero.
+func appendMessageIndexes(b []byte, indexes []int) []byte {
+	if len(indexes) == 1 && indexes[0] == 0 {
+		return append(b, 0)
+	}
+	b = binary.AppendVarint(b, int64(len(indexes)))
+	for _, i := range indexes {
+		b = binary.AppendVarint(b, int64(i))
+	}
+	return b
+}
+
+// parseMessageIndexes reads the message indexes that follow the header of a Protobuf payload.
+// The payload comes from the topic, so the count is checked before anything is allocated.
+func parseMessageIndexes(data []byte) ([]int, []byte, error) {
+	count, n := binary.Varint(data)
+	if n <= 0 {
+		return nil, nil, fmt.Errorf("invalid Protobuf message indexes")
+	}
+	data = data[n:]
+	if count == 0 {
+		return []int{0}, data, nil
+	}
+	// Every index takes at least one byte
+	if count < 0 || count > int64(len(data)) {
+		return nil, nil, fmt.Errorf("invalid Protobuf message index count %d", count)
+	}
+
+	indexes := make([]int, 0, count)
+	for i := int64(0); i < count; i++ {
+		index, n := binary.Varint(data)
+		if n <= 0 || index < 0 {
+			return nil, nil, fmt.Errorf("invalid Protobuf message indexes")
+		}
+		indexes = append(indexes, int(index))
+		data = data[n:]
+	}
+	return indexes, data, nil
+}
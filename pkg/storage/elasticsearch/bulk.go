@@ -0,0 +1,362 @@
+package elasticsearch
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"yourapp/pkg/logger"
+	"yourapp/pkg/metrics"
+
+	"github.com/elastic/go-elasticsearch/v8/esutil"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	bulkItems = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "elasticsearch_bulk_items_total",
+		Help: "Bulk items by result: success, failure or retry.",
+	}, []string{"index", "result"})
+	bulkFlushes = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "elasticsearch_bulk_flushes_total",
+		Help: "Bulk requests sent.",
+	}, []string{"index"})
+	bulkFlushDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "elasticsearch_bulk_flush_duration_seconds",
+		Help:    "Time taken by bulk requests.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"index"})
+)
+
+func init() {
+	metrics.Registry.MustRegister(bulkItems, bulkFlushes, bulkFlushDuration)
+}
+
+// bulkCloseTimeout bounds how long Close waits for open bulk indexers to flush
+const bulkCloseTimeout = 30 * time.Second
+
+// Bulk actions
+const (
+	ActionIndex  = "index"
+	ActionCreate = "create"
+	ActionUpdate = "update"
+	ActionDelete = "delete"
+)
+
+// BulkItem is one operation added to a BulkIndexer
+type BulkItem struct {
+	// Action is one of the Action constants; empty means ActionIndex
+	Action string
+	// Index overrides the indexer's default index
+	Index   string
+	ID      string
+	Routing string
+	// Body is the document, or for updates the update request body such as {"doc": ...}.
+	// It is a string, []byte or io.Reader holding JSON, or any value encoded as JSON.
+	Body any
+
+	// OnSuccess and OnFailure are called from the indexer's workers once the item is settled.
+	// Items rejected with 429 are retried first and only reported when retries run out.
+	OnSuccess func(ctx context.Context, item BulkItem, res esutil.BulkIndexerResponseItem)
+	OnFailure func(ctx context.Context, item BulkItem, res esutil.BulkIndexerResponseItem, err error)
+}
+
+// BulkStats counts the items of a BulkIndexer
+type BulkStats struct {
+	Added    uint64
+	Flushed  uint64
+	Failed   uint64
+	Indexed  uint64
+	Created  uint64
+	Updated  uint64
+	Deleted  uint64
+	Requests uint64
+	Retried  uint64
+}
+
+// bulkRequest is an item with its encoded body, kept so it can be added again
+type bulkRequest struct {
+	item    BulkItem
+	body    []byte
+	attempt int
+}
+
+// BulkIndexer batches document operations into bulk requests sent by concurrent workers.
+// Items rejected because the cluster is overloaded (HTTP 429) are added again with
+// exponential backoff.
+type BulkIndexer struct {
+	index      string
+	config     esutil.BulkIndexerConfig
+	maxRetries int
+	backoff    time.Duration
+
+	mu      sync.RWMutex
+	indexer esutil.BulkIndexer
+	closing bool
+	timers  sync.WaitGroup
+
+	leftoverMu sync.Mutex
+	leftover   []*bulkRequest
+
+	// stats of indexers already closed while draining retries
+	done    BulkStats
+	retried atomic.Uint64
+}
+
+var (
+	indexersMu sync.Mutex
+	indexers   = make(map[*BulkIndexer]struct{})
+)
+
+// NewBulkIndexer creates a bulk indexer writing to index by default, configured from the
+// elasticsearch.bulk settings. It must be closed to flush the remaining items; Close closes
+// the indexers still open at shutdown.
+func NewBulkIndexer(index string) (*BulkIndexer, error) {
+	if client == nil {
+		return nil, fmt.Errorf("Elasticsearch client not initialized")
+	}
+
+	cfg := esConfig.Bulk
+	b := &BulkIndexer{
+		index:      index,
+		maxRetries: cfg.MaxRetries,
+		backoff:    cfg.RetryBackoff,
+	}
+	b.config = esutil.BulkIndexerConfig{
+		Client:        client,
+		Index:         index,
+		NumWorkers:    cfg.Workers,
+		FlushBytes:    cfg.FlushBytes,
+		FlushInterval: cfg.FlushInterval,
+		OnError: func(ctx context.Context, err error) {
+			logger.Errorf("Bulk indexer for %s: %v", index, err)
+		},
+		OnFlushStart: func(ctx context.Context) context.Context {
+			return context.WithValue(ctx, flushStartKey{}, time.Now())
+		},
+		OnFlushEnd: func(ctx context.Context) {
+			bulkFlushes.WithLabelValues(index).Inc()
+			if start, ok := ctx.Value(flushStartKey{}).(time.Time); ok {
+				bulkFlushDuration.WithLabelValues(index).Observe(time.Since(start).Seconds())
+			}
+		},
+	}
+
+	var err error
+	if b.indexer, err = esutil.NewBulkIndexer(b.config); err != nil {
+		return nil, fmt.Errorf("failed to create bulk indexer: %w", err)
+	}
+
+	indexersMu.Lock()
+	indexers[b] = struct{}{}
+	indexersMu.Unlock()
+	return b, nil
+}
+
+type flushStartKey struct{}
+
+// Add queues an item. It blocks while the workers are busy and fails once Close was called.
+func (b *BulkIndexer) Add(ctx context.Context, item BulkItem) error {
+	var body []byte
+	if item.Body != nil {
+		reader, err := requestBody(item.Body)
+		if err != nil {
+			return err
+		}
+		var buf bytes.Buffer
+		if _, err := buf.ReadFrom(reader); err != nil {
+			return fmt.Errorf("failed to read bulk item body: %w", err)
+		}
+		body = buf.Bytes()
+	}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if b.closing {
+		return fmt.Errorf("bulk indexer for %s is closed", b.index)
+	}
+	return b.indexer.Add(ctx, b.esItem(&bulkRequest{item: item, body: body}))
+}
+
+// esItem converts a request for the underlying indexer
+func (b *BulkIndexer) esItem(r *bulkRequest) esutil.BulkIndexerItem {
+	action := r.item.Action
+	if action == "" {
+		action = ActionIndex
+	}
+	index := r.item.Index
+	if index == "" {
+		index = b.index
+	}
+
+	item := esutil.BulkIndexerItem{
+		Action:     action,
+		Index:      index,
+		DocumentID: r.item.ID,
+		Routing:    r.item.Routing,
+		OnSuccess: func(ctx context.Context, _ esutil.BulkIndexerItem, res esutil.BulkIndexerResponseItem) {
+			bulkItems.WithLabelValues(index, "success").Inc()
+			if r.item.OnSuccess != nil {
+				r.item.OnSuccess(ctx, r.item, res)
+			}
+		},
+		OnFailure: func(ctx context.Context, _ esutil.BulkIndexerItem, res esutil.BulkIndexerResponseItem, err error) {
+			if err == nil && res.Status == http.StatusTooManyRequests && r.attempt < b.maxRetries {
+				bulkItems.WithLabelValues(index, "retry").Inc()
+				b.retry(r)
+				return
+			}
+
+			bulkItems.WithLabelValues(index, "failure").Inc()
+			if err == nil {
+				err = &Error{StatusCode: res.Status, Type: res.Error.Type, Reason: res.Error.Reason}
+			}
+			if r.item.OnFailure != nil {
+				r.item.OnFailure(ctx, r.item, res, err)
+			} else {
+				logger.Errorf("Bulk %s of %s/%s failed: %v", action, index, r.item.ID, err)
+			}
+		},
+	}
+	if r.body != nil {
+		item.Body = bytes.NewReader(r.body)
+	}
+	return item
+}
+
+// retry adds a rejected item again after its backoff
+func (b *BulkIndexer) retry(r *bulkRequest) {
+	b.retried.Add(1)
+	next := &bulkRequest{item: r.item, body: r.body, attempt: r.attempt + 1}
+	delay := b.backoff << r.attempt
+
+	b.timers.Add(1)
+	time.AfterFunc(delay, func() {
+		defer b.timers.Done()
+
+		b.mu.RLock()
+		if !b.closing {
+			err := b.indexer.Add(context.Background(), b.esItem(next))
+			b.mu.RUnlock()
+			if err != nil {
+				logger.Errorf("Failed to retry bulk item %s: %v", next.item.ID, err)
+			}
+			return
+		}
+		b.mu.RUnlock()
+
+		// Close sends these once the indexer has drained
+		b.leftoverMu.Lock()
+		b.leftover = append(b.leftover, next)
+		b.leftoverMu.Unlock()
+	})
+}
+
+// Close flushes the queued items, waits for retries to finish and logs the final statistics
+func (b *BulkIndexer) Close(ctx context.Context) error {
+	indexersMu.Lock()
+	delete(indexers, b)
+	indexersMu.Unlock()
+
+	b.mu.Lock()
+	if b.closing {
+		b.mu.Unlock()
+		return nil
+	}
+	b.closing = true
+	b.mu.Unlock()
+
+	err := b.indexer.Close(ctx)
+	b.timers.Wait()
+
+	// Items rejected during the final flush are sent by fresh indexers until retries run out
+	for err == nil {
+		b.leftoverMu.Lock()
+		pending := b.leftover
+		b.leftover = nil
+		b.leftoverMu.Unlock()
+
+		b.mu.Lock()
+		b.done = addStats(b.done, b.indexer.Stats())
+		b.mu.Unlock()
+		if len(pending) == 0 {
+			break
+		}
+
+		var next esutil.BulkIndexer
+		if next, err = esutil.NewBulkIndexer(b.config); err != nil {
+			break
+		}
+		b.mu.Lock()
+		b.indexer = next
+		b.mu.Unlock()
+		for _, r := range pending {
+			if err = next.Add(ctx, b.esItem(r)); err != nil {
+				break
+			}
+		}
+		if closeErr := next.Close(ctx); err == nil {
+			err = closeErr
+		}
+		b.timers.Wait()
+	}
+
+	s := b.Stats()
+	logger.Infof("Bulk indexer for %s closed: %d added, %d flushed, %d failed, %d retried, %d requests",
+		b.index, s.Added, s.Flushed, s.Failed, s.Retried, s.Requests)
+
+	if err != nil {
+		return fmt.Errorf("failed to close bulk indexer for %s: %w", b.index, err)
+	}
+	return nil
+}
+
+// Stats returns the item counts so far. Retried attempts are counted once in Added and not
+// at all in Failed.
+func (b *BulkIndexer) Stats() BulkStats {
+	b.mu.RLock()
+	s := b.done
+	if !b.closing {
+		s = addStats(s, b.indexer.Stats())
+	}
+	b.mu.RUnlock()
+
+	s.Retried = b.retried.Load()
+	s.Added -= min(s.Added, s.Retried)
+	s.Failed -= min(s.Failed, s.Retried)
+	return s
+}
+
+func addStats(s BulkStats, es esutil.BulkIndexerStats) BulkStats {
+	s.Added += es.NumAdded
+	s.Flushed += es.NumFlushed
+	s.Failed += es.NumFailed
+	s.Indexed += es.NumIndexed
+	s.Created += es.NumCreated
+	s.Updated += es.NumUpdated
+	s.Deleted += es.NumDeleted
+	s.Requests += es.NumRequests
+	return s
+}
+
+// closeIndexers closes the bulk indexers that are still open
+func closeIndexers(ctx context.Context) error {
+	indexersMu.Lock()
+	open := make([]*BulkIndexer, 0, len(indexers))
+	for b := range indexers {
+		open = append(open, b)
+	}
+	indexersMu.Unlock()
+
+	var err error
+	for _, b := range open {
+		if closeErr := b.Close(ctx); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
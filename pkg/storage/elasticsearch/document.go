@@ -0,0 +1,350 @@
+package elasticsearch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/elastic/go-elasticsearch/v8/esapi"
+)
+
+// Index is a typed repository for the documents of one index (or alias). Documents are
+// encoded as JSON, so T uses json tags for its field names.
+type Index[T any] struct {
+	name string
+}
+
+// NewIndex returns a repository for the named index
+func NewIndex[T any](name string) *Index[T] {
+	return &Index[T]{name: name}
+}
+
+// Name returns the name of the index
+func (i *Index[T]) Name() string {
+	return i.name
+}
+
+// Document is a stored document with its metadata
+type Document[T any] struct {
+	ID          string
+	Index       string
+	Version     int64
+	SeqNo       int64
+	PrimaryTerm int64
+	Source      T
+}
+
+// Script is a stored or inline Painless script
+type Script struct {
+	Source string         `json:"source,omitempty"`
+	ID     string         `json:"id,omitempty"`
+	Lang   string         `json:"lang,omitempty"`
+	Params map[string]any `json:"params,omitempty"`
+}
+
+// WriteOption configures a write request
+type WriteOption func(*writeOptions)
+
+type writeOptions struct {
+	refresh         string
+	routing         string
+	ifSeqNo         *int
+	ifPrimaryTerm   *int
+	retryOnConflict *int
+	upsert          any
+}
+
+// WithRefresh sets the refresh policy of a write: "true", "false" or "wait_for"
+func WithRefresh(policy string) WriteOption {
+	return func(o *writeOptions) { o.refresh = policy }
+}
+
+// WithRouting routes the document to the shard for the given value
+func WithRouting(routing string) WriteOption {
+	return func(o *writeOptions) { o.routing = routing }
+}
+
+// IfMatch makes the write fail with ErrConflict unless the document is still at the given
+// sequence number and primary term, as returned by Get
+func IfMatch(seqNo, primaryTerm int64) WriteOption {
+	return func(o *writeOptions) {
+		s, p := int(seqNo), int(primaryTerm)
+		o.ifSeqNo, o.ifPrimaryTerm = &s, &p
+	}
+}
+
+// WithRetryOnConflict retries an update that conflicts with a concurrent write
+func WithRetryOnConflict(n int) WriteOption {
+	return func(o *writeOptions) { o.retryOnConflict = &n }
+}
+
+// WithUpsert indexes doc when the updated document does not exist
+func WithUpsert(doc any) WriteOption {
+	return func(o *writeOptions) { o.upsert = doc }
+}
+
+func newWriteOptions(opts []WriteOption) *writeOptions {
+	o := &writeOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// Get returns the document with the given ID; a missing document yields an error matching
+// ErrNotFound
+func (i *Index[T]) Get(ctx context.Context, id string) (*Document[T], error) {
+	var res struct {
+		Index       string `json:"_index"`
+		ID          string `json:"_id"`
+		Version     int64  `json:"_version"`
+		SeqNo       int64  `json:"_seq_no"`
+		PrimaryTerm int64  `json:"_primary_term"`
+		Source      T      `json:"_source"`
+	}
+	req := esapi.GetRequest{Index: i.name, DocumentID: id}
+	if err := perform(ctx, req, &res); err != nil {
+		return nil, fmt.Errorf("failed to get document %s from %s: %w", id, i.name, err)
+	}
+
+	return &Document[T]{
+		ID:          res.ID,
+		Index:       res.Index,
+		Version:     res.Version,
+		SeqNo:       res.SeqNo,
+		PrimaryTerm: res.PrimaryTerm,
+		Source:      res.Source,
+	}, nil
+}
+
+// Index stores doc under id, replacing any existing document, and returns the ID. An empty
+// id lets Elasticsearch generate one.
+func (i *Index[T]) Index(ctx context.Context, id string, doc T, opts ...WriteOption) (string, error) {
+	body, err := requestBody(doc)
+	if err != nil {
+		return "", err
+	}
+
+	o := newWriteOptions(opts)
+	req := esapi.IndexRequest{
+		Index:         i.name,
+		DocumentID:    id,
+		Body:          body,
+		Refresh:       o.refresh,
+		Routing:       o.routing,
+		IfSeqNo:       o.ifSeqNo,
+		IfPrimaryTerm: o.ifPrimaryTerm,
+	}
+	var res struct {
+		ID string `json:"_id"`
+	}
+	if err := perform(ctx, req, &res); err != nil {
+		return "", fmt.Errorf("failed to index document %s in %s: %w", id, i.name, err)
+	}
+	return res.ID, nil
+}
+
+// Update merges the fields of partial, a struct or map with the fields to change, into the
+// document
+func (i *Index[T]) Update(ctx context.Context, id string, partial any, opts ...WriteOption) error {
+	o := newWriteOptions(opts)
+	body := map[string]any{"doc": partial}
+	if o.upsert != nil {
+		body["upsert"] = o.upsert
+	}
+	return i.update(ctx, id, body, o)
+}
+
+// UpdateScript updates the document by running script on it
+func (i *Index[T]) UpdateScript(ctx context.Context, id string, script Script, opts ...WriteOption) error {
+	o := newWriteOptions(opts)
+	body := map[string]any{"script": script}
+	if o.upsert != nil {
+		body["upsert"] = o.upsert
+	}
+	return i.update(ctx, id, body, o)
+}
+
+func (i *Index[T]) update(ctx context.Context, id string, body map[string]any, o *writeOptions) error {
+	reader, err := requestBody(body)
+	if err != nil {
+		return err
+	}
+
+	req := esapi.UpdateRequest{
+		Index:           i.name,
+		DocumentID:      id,
+		Body:            reader,
+		Refresh:         o.refresh,
+		Routing:         o.routing,
+		IfSeqNo:         o.ifSeqNo,
+		IfPrimaryTerm:   o.ifPrimaryTerm,
+		RetryOnConflict: o.retryOnConflict,
+	}
+	if err := perform(ctx, req, nil); err != nil {
+		return fmt.Errorf("failed to update document %s in %s: %w", id, i.name, err)
+	}
+	return nil
+}
+
+// Delete deletes the document with the given ID
+func (i *Index[T]) Delete(ctx context.Context, id string, opts ...WriteOption) error {
+	o := newWriteOptions(opts)
+	req := esapi.DeleteRequest{
+		Index:         i.name,
+		DocumentID:    id,
+		Refresh:       o.refresh,
+		Routing:       o.routing,
+		IfSeqNo:       o.ifSeqNo,
+		IfPrimaryTerm: o.ifPrimaryTerm,
+	}
+	if err := perform(ctx, req, nil); err != nil {
+		return fmt.Errorf("failed to delete document %s from %s: %w", id, i.name, err)
+	}
+	return nil
+}
+
+// SearchResult is the typed response of a search
+type SearchResult[T any] struct {
+	Took     int
+	TimedOut bool
+	// Total is the number of matching documents; TotalRelation is "gte" when it is a lower bound
+	Total         int64
+	TotalRelation string
+	MaxScore      *float64
+	Hits          []Hit[T]
+	Aggregations  map[string]json.RawMessage
+}
+
+// Hit is one document matched by a search
+type Hit[T any] struct {
+	ID        string
+	Index     string
+	Score     *float64
+	Source    T
+	Highlight map[string][]string
+	// Sort holds the sort values of the hit, used to page with search_after
+	Sort []any
+}
+
+// Aggregation decodes the named aggregation result into v
+func (r *SearchResult[T]) Aggregation(name string, v any) error {
+	raw, ok := r.Aggregations[name]
+	if !ok {
+		return fmt.Errorf("no aggregation named %s in search result", name)
+	}
+	return json.Unmarshal(raw, v)
+}
+
+// searchResponse is the JSON shape of a search response
+type searchResponse[T any] struct {
+	Took     int  `json:"took"`
+	TimedOut bool `json:"timed_out"`
+	// PitID and ScrollID are the cursors of a scan; see Index.Scan
+	PitID    string `json:"pit_id"`
+	ScrollID string `json:"_scroll_id"`
+	Hits     struct {
+		Total struct {
+			Value    int64  `json:"value"`
+			Relation string `json:"relation"`
+		} `json:"total"`
+		MaxScore *float64 `json:"max_score"`
+		Hits     []struct {
+			Index     string              `json:"_index"`
+			ID        string              `json:"_id"`
+			Score     *float64            `json:"_score"`
+			Source    T                   `json:"_source"`
+			Highlight map[string][]string `json:"highlight"`
+			Sort      []any               `json:"sort"`
+		} `json:"hits"`
+	} `json:"hits"`
+	Aggregations map[string]json.RawMessage `json:"aggregations"`
+}
+
+func (r *searchResponse[T]) result() *SearchResult[T] {
+	result := &SearchResult[T]{
+		Took:          r.Took,
+		TimedOut:      r.TimedOut,
+		Total:         r.Hits.Total.Value,
+		TotalRelation: r.Hits.Total.Relation,
+		MaxScore:      r.Hits.MaxScore,
+		Hits:          make([]Hit[T], len(r.Hits.Hits)),
+		Aggregations:  r.Aggregations,
+	}
+	for n, h := range r.Hits.Hits {
+		result.Hits[n] = Hit[T]{
+			ID:        h.ID,
+			Index:     h.Index,
+			Score:     h.Score,
+			Source:    h.Source,
+			Highlight: h.Highlight,
+			Sort:      h.Sort,
+		}
+	}
+	return result
+}
+
+// Search runs a search request on the index. query is the request body: a string, []byte
+// or io.Reader holding JSON, or any value encoded as JSON.
+func (i *Index[T]) Search(ctx context.Context, query any) (*SearchResult[T], error) {
+	body, err := requestBody(query)
+	if err != nil {
+		return nil, err
+	}
+
+	var res searchResponse[T]
+	req := esapi.SearchRequest{Index: []string{i.name}, Body: body}
+	if err := perform(ctx, req, &res); err != nil {
+		return nil, fmt.Errorf("failed to search %s: %w", i.name, err)
+	}
+	return res.result(), nil
+}
+
+// requestBody returns a reader for a request body given as JSON text or a value to encode
+func requestBody(v any) (io.Reader, error) {
+	switch b := v.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		return strings.NewReader(b), nil
+	case []byte:
+		return bytes.NewReader(b), nil
+	case io.Reader:
+		return b, nil
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode request body: %w", err)
+	}
+	return bytes.NewReader(data), nil
+}
+
+// perform runs a request with the package client and decodes a successful JSON response
+// into out when it is not nil. Error responses are returned as *Error.
+func perform(ctx context.Context, req esapi.Request, out any) error {
+	if client == nil {
+		return fmt.Errorf("Elasticsearch client not initialized")
+	}
+
+	res, err := req.Do(ctx, client)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return decodeError(res)
+	}
+	if out == nil {
+		_, _ = io.Copy(io.Discard, res.Body)
+		return nil
+	}
+	if err := json.NewDecoder(res.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
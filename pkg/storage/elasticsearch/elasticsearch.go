@@ -2,9 +2,14 @@ package elasticsearch
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
+	"net"
 	"net/http"
+	"os"
 	"strings"
+	"time"
 
 	"yourapp/pkg/config"
 
@@ -13,28 +18,29 @@ import (
 )
 
 var (
-	client *elasticsearch.Client
+	client   *elasticsearch.Client
+	esConfig config.ElasticsearchConfig
 )
 
+// maxRetryBackoff caps the exponential backoff between retries of a request
+const maxRetryBackoff = 10 * time.Second
+
 // Init initializes the Elasticsearch connection
 func Init(ctx context.Context, cfg config.ElasticsearchConfig) error {
-	esConfig := elasticsearch.Config{
-		Addresses: []string{fmt.Sprintf("http://%s:%d", cfg.Host, cfg.Port)},
-		Username:  cfg.Username,
-		Password:  cfg.Password,
-		Transport: &http.Transport{
-			MaxIdleConnsPerHost: cfg.MaxIdleConnsPerHost,
-		},
+	esConfig = cfg
+
+	clientConfig, err := newClientConfig(cfg)
+	if err != nil {
+		return err
 	}
 
-	var err error
-	client, err = elasticsearch.NewClient(esConfig)
+	client, err = elasticsearch.NewClient(clientConfig)
 	if err != nil {
 		return fmt.Errorf("failed to create Elasticsearch client: %w", err)
 	}
 
 	// Test the connection
-	res, err := client.Ping()
+	res, err := client.Ping(client.Ping.WithContext(ctx))
 	if err != nil {
 		return fmt.Errorf("failed to ping Elasticsearch: %w", err)
 	}
@@ -47,6 +53,87 @@ func Init(ctx context.Context, cfg config.ElasticsearchConfig) error {
 	return nil
 }
 
+// newClientConfig builds the client configuration: the nodes to connect to, authentication,
+// TLS, retries and node discovery
+func newClientConfig(cfg config.ElasticsearchConfig) (elasticsearch.Config, error) {
+	addresses := cfg.Addresses
+	if len(addresses) == 0 && cfg.CloudID == "" {
+		scheme := "http"
+		if cfg.TLS.Enabled {
+			scheme = "https"
+		}
+		addresses = []string{fmt.Sprintf("%s://%s:%d", scheme, cfg.Host, cfg.Port)}
+	}
+
+	tlsConfig, err := newTLSConfig(cfg.TLS)
+	if err != nil {
+		return elasticsearch.Config{}, err
+	}
+
+	dialer := &net.Dialer{Timeout: cfg.Timeout, KeepAlive: 30 * time.Second}
+	transport := &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           dialer.DialContext,
+		TLSClientConfig:       tlsConfig,
+		TLSHandshakeTimeout:   cfg.Timeout,
+		ResponseHeaderTimeout: cfg.Timeout,
+		MaxIdleConnsPerHost:   cfg.MaxIdleConnsPerHost,
+		IdleConnTimeout:       90 * time.Second,
+	}
+
+	backoff := cfg.RetryBackoff
+	return elasticsearch.Config{
+		Addresses:              addresses,
+		CloudID:                cfg.CloudID,
+		Username:               cfg.Username,
+		Password:               cfg.Password,
+		APIKey:                 cfg.APIKey,
+		CertificateFingerprint: cfg.TLS.CAFingerprint,
+		RetryOnStatus:          cfg.RetryOnStatus,
+		MaxRetries:             cfg.MaxRetries,
+		DisableRetry:           cfg.MaxRetries < 0,
+		RetryBackoff: func(attempt int) time.Duration {
+			if backoff <= 0 {
+				return 0
+			}
+			return min(backoff<<(attempt-1), maxRetryBackoff)
+		},
+		DiscoverNodesOnStart:  cfg.DiscoverNodesOnStart,
+		DiscoverNodesInterval: cfg.DiscoverNodesInterval,
+		Transport:             transport,
+	}, nil
+}
+
+// newTLSConfig loads the CA and client certificate files. A CA fingerprint is checked by the
+// client itself when connecting.
+func newTLSConfig(cfg config.ESTLSConfig) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: cfg.InsecureSkipVerify,
+	}
+
+	if cfg.CAFile != "" {
+		ca, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read Elasticsearch CA file: %w", err)
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("no certificates found in Elasticsearch CA file %s", cfg.CAFile)
+		}
+	}
+
+	if cfg.CertFile != "" || cfg.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load Elasticsearch client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
+
 // GetClient returns the Elasticsearch client
 func GetClient() *elasticsearch.Client {
 	return client
@@ -54,8 +141,10 @@ func GetClient() *elasticsearch.Client {
 
 // Close closes the Elasticsearch connection
 func Close() error {
-	// Elasticsearch client doesn't need explicit closing
-	return nil
+	// The client itself doesn't need explicit closing, but open bulk indexers must be flushed
+	ctx, cancel := context.WithTimeout(context.Background(), bulkCloseTimeout)
+	defer cancel()
+	return closeIndexers(ctx)
 }
 
 // Health checks the health of the Elasticsearch connection
@@ -64,7 +153,7 @@ func Health(ctx context.Context) error {
 		return fmt.Errorf("Elasticsearch client not initialized")
 	}
 
-	res, err := client.Cluster.Health()
+	res, err := client.Cluster.Health(client.Cluster.Health.WithContext(ctx))
 	if err != nil {
 		return err
 	}
@@ -83,18 +172,19 @@ func CreateIndex(ctx context.Context, indexName string, mapping string) error {
 		return fmt.Errorf("Elasticsearch client not initialized")
 	}
 
-	res, err := client.Indices.Create(
-		indexName,
-		client.Indices.Create.WithBody(strings.NewReader(mapping)),
-		client.Indices.Create.WithContext(ctx),
-	)
+	opts := []func(*esapi.IndicesCreateRequest){client.Indices.Create.WithContext(ctx)}
+	if mapping != "" {
+		opts = append(opts, client.Indices.Create.WithBody(strings.NewReader(mapping)))
+	}
+
+	res, err := client.Indices.Create(indexName, opts...)
 	if err != nil {
 		return fmt.Errorf("failed to create index %s: %w", indexName, err)
 	}
 	defer res.Body.Close()
 
 	if res.IsError() {
-		return fmt.Errorf("failed to create index %s with status: %s", indexName, res.Status())
+		return fmt.Errorf("failed to create index %s: %w", indexName, decodeError(res))
 	}
 
 	return nil
@@ -116,7 +206,7 @@ func DeleteIndex(ctx context.Context, indexName string) error {
 	defer res.Body.Close()
 
 	if res.IsError() {
-		return fmt.Errorf("failed to delete index %s with status: %s", indexName, res.Status())
+		return fmt.Errorf("failed to delete index %s: %w", indexName, decodeError(res))
 	}
 
 	return nil
@@ -140,7 +230,7 @@ func IndexDocument(ctx context.Context, indexName, documentID string, document s
 	defer res.Body.Close()
 
 	if res.IsError() {
-		return fmt.Errorf("failed to index document with status: %s", res.Status())
+		return fmt.Errorf("failed to index document: %w", decodeError(res))
 	}
 
 	return nil
@@ -162,7 +252,8 @@ func Search(ctx context.Context, indexName string, query string) (*esapi.Respons
 	}
 
 	if res.IsError() {
-		return nil, fmt.Errorf("search failed with status: %s", res.Status())
+		defer res.Body.Close()
+		return nil, fmt.Errorf("search failed: %w", decodeError(res))
 	}
 
 	return res, nil
@@ -0,0 +1,109 @@
+package elasticsearch
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/elastic/go-elasticsearch/v8/esapi"
+)
+
+// Sentinel errors matched by errors.Is against an *Error
+var (
+	ErrNotFound   = errors.New("elasticsearch: not found")
+	ErrConflict   = errors.New("elasticsearch: version conflict")
+	ErrBadRequest = errors.New("elasticsearch: bad request")
+	// ErrTooManyRequests is returned when the cluster rejects a request because it is overloaded
+	ErrTooManyRequests = errors.New("elasticsearch: too many requests")
+)
+
+// ErrorCause is one cause of an Elasticsearch error
+type ErrorCause struct {
+	Type   string `json:"type"`
+	Reason string `json:"reason"`
+	Index  string `json:"index,omitempty"`
+}
+
+// Error is an error response from Elasticsearch
+type Error struct {
+	StatusCode int
+	Type       string
+	Reason     string
+	RootCause  []ErrorCause
+	// Body is the raw response body when it could not be decoded
+	Body string
+}
+
+func (e *Error) Error() string {
+	switch {
+	case e.Type != "":
+		return fmt.Sprintf("elasticsearch: %s (%d): %s", e.Type, e.StatusCode, e.Reason)
+	case e.Body != "":
+		return fmt.Sprintf("elasticsearch: HTTP %d: %s", e.StatusCode, e.Body)
+	}
+	return fmt.Sprintf("elasticsearch: HTTP %d", e.StatusCode)
+}
+
+// Is matches the sentinel error for the response status
+func (e *Error) Is(target error) bool {
+	switch target {
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound
+	case ErrConflict:
+		return e.StatusCode == http.StatusConflict
+	case ErrBadRequest:
+		return e.StatusCode == http.StatusBadRequest
+	case ErrTooManyRequests:
+		return e.StatusCode == http.StatusTooManyRequests
+	}
+	return false
+}
+
+// IsNotFound reports whether err is an Elasticsearch not found error
+func IsNotFound(err error) bool {
+	return errors.Is(err, ErrNotFound)
+}
+
+// decodeError reads the body of an error response into an *Error. Elasticsearch reports
+// most errors as {"error": {"type", "reason", "root_cause"}, "status"}, some as a plain
+// error string, and missing documents only with "found": false.
+func decodeError(res *esapi.Response) error {
+	e := &Error{StatusCode: res.StatusCode}
+
+	data, err := io.ReadAll(res.Body)
+	if err != nil || len(data) == 0 {
+		return e
+	}
+
+	var body struct {
+		Error  json.RawMessage `json:"error"`
+		Found  *bool           `json:"found"`
+		Result string          `json:"result"`
+	}
+	if json.Unmarshal(data, &body) != nil {
+		e.Body = string(data)
+		return e
+	}
+
+	switch {
+	case len(body.Error) > 0 && body.Error[0] == '{':
+		var detail struct {
+			Type      string       `json:"type"`
+			Reason    string       `json:"reason"`
+			RootCause []ErrorCause `json:"root_cause"`
+		}
+		if json.Unmarshal(body.Error, &detail) == nil {
+			e.Type, e.Reason, e.RootCause = detail.Type, detail.Reason, detail.RootCause
+		}
+	case len(body.Error) > 0:
+		_ = json.Unmarshal(body.Error, &e.Reason)
+		e.Type = "error"
+	case body.Found != nil && !*body.Found, body.Result == "not_found":
+		e.Type, e.Reason = "not_found", "document not found"
+	default:
+		e.Body = string(data)
+	}
+	return e
+}
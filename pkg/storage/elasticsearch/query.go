@@ -0,0 +1,394 @@
+package elasticsearch
+
+import "encoding/json"
+
+// Query is a clause of the query DSL
+type Query interface {
+	// Source returns the JSON-encodable form of the clause
+	Source() any
+}
+
+// rawQuery is a clause given as its JSON form
+type rawQuery map[string]any
+
+func (q rawQuery) Source() any { return map[string]any(q) }
+
+// RawQuery wraps a clause the builder does not cover
+func RawQuery(q map[string]any) Query {
+	return rawQuery(q)
+}
+
+// MatchAll matches every document
+func MatchAll() Query {
+	return rawQuery{"match_all": map[string]any{}}
+}
+
+// Term matches documents whose field holds exactly value
+func Term(field string, value any) Query {
+	return rawQuery{"term": map[string]any{field: map[string]any{"value": value}}}
+}
+
+// Terms matches documents whose field holds any of values
+func Terms(field string, values ...any) Query {
+	return rawQuery{"terms": map[string]any{field: values}}
+}
+
+// Match runs a full text query on field
+func Match(field string, text any) Query {
+	return rawQuery{"match": map[string]any{field: map[string]any{"query": text}}}
+}
+
+// MatchPhrase matches the words of text in order
+func MatchPhrase(field string, text string) Query {
+	return rawQuery{"match_phrase": map[string]any{field: map[string]any{"query": text}}}
+}
+
+// MultiMatch runs a full text query on several fields
+func MultiMatch(text string, fields ...string) Query {
+	return rawQuery{"multi_match": map[string]any{"query": text, "fields": fields}}
+}
+
+// Exists matches documents that have a value for field
+func Exists(field string) Query {
+	return rawQuery{"exists": map[string]any{"field": field}}
+}
+
+// IDs matches documents by ID
+func IDs(ids ...string) Query {
+	return rawQuery{"ids": map[string]any{"values": ids}}
+}
+
+// Nested runs q on the nested objects at path and matches their parent documents
+func Nested(path string, q Query) Query {
+	return rawQuery{"nested": map[string]any{"path": path, "query": q.Source()}}
+}
+
+// RangeQuery matches documents whose field lies in a range
+type RangeQuery struct {
+	field  string
+	params map[string]any
+}
+
+// Range starts a range query on field
+func Range(field string) *RangeQuery {
+	return &RangeQuery{field: field, params: make(map[string]any)}
+}
+
+// Gt sets an exclusive lower bound
+func (q *RangeQuery) Gt(v any) *RangeQuery { q.params["gt"] = v; return q }
+
+// Gte sets an inclusive lower bound
+func (q *RangeQuery) Gte(v any) *RangeQuery { q.params["gte"] = v; return q }
+
+// Lt sets an exclusive upper bound
+func (q *RangeQuery) Lt(v any) *RangeQuery { q.params["lt"] = v; return q }
+
+// Lte sets an inclusive upper bound
+func (q *RangeQuery) Lte(v any) *RangeQuery { q.params["lte"] = v; return q }
+
+// Format sets the date format of the bounds
+func (q *RangeQuery) Format(format string) *RangeQuery { q.params["format"] = format; return q }
+
+// Source implements Query
+func (q *RangeQuery) Source() any {
+	return map[string]any{"range": map[string]any{q.field: q.params}}
+}
+
+// BoolQuery combines clauses: all of must, at least one of should, all of filter without
+// scoring, and none of must_not
+type BoolQuery struct {
+	must, should, filter, mustNot []Query
+	minimumShouldMatch            any
+}
+
+// Bool starts a bool query
+func Bool() *BoolQuery {
+	return &BoolQuery{}
+}
+
+// Must adds clauses that must match and contribute to the score
+func (q *BoolQuery) Must(clauses ...Query) *BoolQuery {
+	q.must = append(q.must, clauses...)
+	return q
+}
+
+// Should adds clauses of which at least one should match
+func (q *BoolQuery) Should(clauses ...Query) *BoolQuery {
+	q.should = append(q.should, clauses...)
+	return q
+}
+
+// Filter adds clauses that must match without contributing to the score
+func (q *BoolQuery) Filter(clauses ...Query) *BoolQuery {
+	q.filter = append(q.filter, clauses...)
+	return q
+}
+
+// MustNot adds clauses that must not match
+func (q *BoolQuery) MustNot(clauses ...Query) *BoolQuery {
+	q.mustNot = append(q.mustNot, clauses...)
+	return q
+}
+
+// MinimumShouldMatch sets how many should clauses must match, as a number or percentage
+func (q *BoolQuery) MinimumShouldMatch(v any) *BoolQuery {
+	q.minimumShouldMatch = v
+	return q
+}
+
+// Source implements Query
+func (q *BoolQuery) Source() any {
+	b := make(map[string]any)
+	for key, clauses := range map[string][]Query{"must": q.must, "should": q.should, "filter": q.filter, "must_not": q.mustNot} {
+		if len(clauses) > 0 {
+			b[key] = sources(clauses)
+		}
+	}
+	if q.minimumShouldMatch != nil {
+		b["minimum_should_match"] = q.minimumShouldMatch
+	}
+	return map[string]any{"bool": b}
+}
+
+func sources(clauses []Query) []any {
+	s := make([]any, len(clauses))
+	for i, c := range clauses {
+		s[i] = c.Source()
+	}
+	return s
+}
+
+// Aggregation is an aggregation of the query DSL
+type Aggregation interface {
+	Source() any
+}
+
+// Agg is an aggregation with optional sub-aggregations
+type Agg struct {
+	kind   string
+	params map[string]any
+	// filter replaces params for filter aggregations, whose body is a query
+	filter Query
+	subs   map[string]Aggregation
+}
+
+// NewAgg creates an aggregation of any kind from its parameters
+func NewAgg(kind string, params map[string]any) *Agg {
+	if params == nil {
+		params = make(map[string]any)
+	}
+	return &Agg{kind: kind, params: params}
+}
+
+// TermsAgg buckets documents by the values of field
+func TermsAgg(field string, size int) *Agg {
+	params := map[string]any{"field": field}
+	if size > 0 {
+		params["size"] = size
+	}
+	return NewAgg("terms", params)
+}
+
+// DateHistogramAgg buckets documents by a calendar interval of field such as "day" or "1M"
+func DateHistogramAgg(field, interval string) *Agg {
+	return NewAgg("date_histogram", map[string]any{"field": field, "calendar_interval": interval})
+}
+
+// AvgAgg averages a numeric field
+func AvgAgg(field string) *Agg { return NewAgg("avg", map[string]any{"field": field}) }
+
+// SumAgg sums a numeric field
+func SumAgg(field string) *Agg { return NewAgg("sum", map[string]any{"field": field}) }
+
+// MinAgg returns the minimum of a numeric field
+func MinAgg(field string) *Agg { return NewAgg("min", map[string]any{"field": field}) }
+
+// MaxAgg returns the maximum of a numeric field
+func MaxAgg(field string) *Agg { return NewAgg("max", map[string]any{"field": field}) }
+
+// CardinalityAgg approximates the number of distinct values of field
+func CardinalityAgg(field string) *Agg {
+	return NewAgg("cardinality", map[string]any{"field": field})
+}
+
+// FilterAgg restricts its sub-aggregations to the documents matching q
+func FilterAgg(q Query) *Agg {
+	return &Agg{kind: "filter", filter: q}
+}
+
+// NestedAgg runs its sub-aggregations on the nested objects at path
+func NestedAgg(path string) *Agg {
+	return NewAgg("nested", map[string]any{"path": path})
+}
+
+// Sub adds a sub-aggregation
+func (a *Agg) Sub(name string, agg Aggregation) *Agg {
+	if a.subs == nil {
+		a.subs = make(map[string]Aggregation)
+	}
+	a.subs[name] = agg
+	return a
+}
+
+// Source implements Aggregation
+func (a *Agg) Source() any {
+	var body any = a.params
+	if a.filter != nil {
+		body = a.filter.Source()
+	}
+	s := map[string]any{a.kind: body}
+	if len(a.subs) > 0 {
+		s["aggs"] = aggSources(a.subs)
+	}
+	return s
+}
+
+func aggSources(aggs map[string]Aggregation) map[string]any {
+	s := make(map[string]any, len(aggs))
+	for name, agg := range aggs {
+		s[name] = agg.Source()
+	}
+	return s
+}
+
+// Sort orders for SearchRequest.Sort
+const (
+	Asc  = "asc"
+	Desc = "desc"
+)
+
+// SearchRequest builds the body of a search request. It encodes as JSON, so it can be
+// passed directly to Index.Search.
+type SearchRequest struct {
+	query          Query
+	from, size     *int
+	sort           []any
+	searchAfter    []any
+	aggs           map[string]Aggregation
+	source         any
+	highlight      map[string]any
+	trackTotalHits any
+}
+
+// NewSearch starts a search request
+func NewSearch() *SearchRequest {
+	return &SearchRequest{}
+}
+
+// Query sets the query
+func (r *SearchRequest) Query(q Query) *SearchRequest {
+	r.query = q
+	return r
+}
+
+// From sets the offset of the first hit
+func (r *SearchRequest) From(n int) *SearchRequest {
+	r.from = &n
+	return r
+}
+
+// Size sets the number of hits to return
+func (r *SearchRequest) Size(n int) *SearchRequest {
+	r.size = &n
+	return r
+}
+
+// Sort adds a sort on field in the given order (Asc or Desc)
+func (r *SearchRequest) Sort(field, order string) *SearchRequest {
+	r.sort = append(r.sort, map[string]any{field: map[string]any{"order": order}})
+	return r
+}
+
+// SortRaw adds a sort clause the builder does not cover, such as a script sort
+func (r *SearchRequest) SortRaw(clause any) *SearchRequest {
+	r.sort = append(r.sort, clause)
+	return r
+}
+
+// SearchAfter continues after the hit with the given sort values; see Hit.Sort
+func (r *SearchRequest) SearchAfter(values ...any) *SearchRequest {
+	r.searchAfter = values
+	return r
+}
+
+// Aggregation adds a named aggregation
+func (r *SearchRequest) Aggregation(name string, agg Aggregation) *SearchRequest {
+	if r.aggs == nil {
+		r.aggs = make(map[string]Aggregation)
+	}
+	r.aggs[name] = agg
+	return r
+}
+
+// Source limits the returned source to fields matching includes and not excludes
+func (r *SearchRequest) Source(includes, excludes []string) *SearchRequest {
+	s := make(map[string]any)
+	if len(includes) > 0 {
+		s["includes"] = includes
+	}
+	if len(excludes) > 0 {
+		s["excludes"] = excludes
+	}
+	r.source = s
+	return r
+}
+
+// NoSource omits the source of hits
+func (r *SearchRequest) NoSource() *SearchRequest {
+	r.source = false
+	return r
+}
+
+// Highlight returns highlighted fragments of the given fields with each hit
+func (r *SearchRequest) Highlight(fields ...string) *SearchRequest {
+	f := make(map[string]any, len(fields))
+	for _, field := range fields {
+		f[field] = map[string]any{}
+	}
+	r.highlight = map[string]any{"fields": f}
+	return r
+}
+
+// TrackTotalHits counts all matches exactly when true, or up to n when given a number
+func (r *SearchRequest) TrackTotalHits(v any) *SearchRequest {
+	r.trackTotalHits = v
+	return r
+}
+
+// Body returns the request body
+func (r *SearchRequest) Body() map[string]any {
+	b := make(map[string]any)
+	if r.query != nil {
+		b["query"] = r.query.Source()
+	}
+	if r.from != nil {
+		b["from"] = *r.from
+	}
+	if r.size != nil {
+		b["size"] = *r.size
+	}
+	if len(r.sort) > 0 {
+		b["sort"] = r.sort
+	}
+	if len(r.searchAfter) > 0 {
+		b["search_after"] = r.searchAfter
+	}
+	if len(r.aggs) > 0 {
+		b["aggs"] = aggSources(r.aggs)
+	}
+	if r.source != nil {
+		b["_source"] = r.source
+	}
+	if r.highlight != nil {
+		b["highlight"] = r.highlight
+	}
+	if r.trackTotalHits != nil {
+		b["track_total_hits"] = r.trackTotalHits
+	}
+	return b
+}
+
+// MarshalJSON implements json.Marshaler
+func (r *SearchRequest) MarshalJSON() ([]byte, error) {
+	return json.Marshal(r.Body())
+}
@@ -0,0 +1,284 @@
+package elasticsearch
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"yourapp/pkg/logger"
+
+	"github.com/elastic/go-elasticsearch/v8/esapi"
+)
+
+// reindexPollInterval is how often the progress of a _reindex task is checked
+const reindexPollInterval = 2 * time.Second
+
+// ReindexOptions configures Reindex
+type ReindexOptions struct {
+	// Alias is the name clients use; the versioned indices are named <Alias>_v<N>
+	Alias string
+	// Mapping is the body used to create the new index: settings, mappings and aliases.
+	// MappingFile is read when Mapping is empty.
+	Mapping     string
+	MappingFile string
+	// Load fills the new index, for example with a BulkIndexer. When nil the documents of the
+	// index the alias currently points to are copied with _reindex.
+	Load func(ctx context.Context, index string) error
+	// Keep is the number of previous versions kept after the swap, for rolling back
+	Keep int
+}
+
+// Reindex builds a new version of an index behind an alias without downtime: it creates
+// <alias>_v<N+1>, loads it, atomically moves the alias to it and deletes old versions
+// beyond opts.Keep. It returns the name of the new index. If loading fails the new index is
+// deleted and the alias is left untouched.
+//
+// Writes keep going to the old index until the swap. When documents are copied with _reindex,
+// a second pass after the swap copies the documents created or updated meanwhile; documents
+// written to the new index since the swap are newer and are kept. Deletes made during the
+// copy are not carried over. With opts.Load, writers must pause until Reindex returns.
+func Reindex(ctx context.Context, opts ReindexOptions) (string, error) {
+	if opts.Alias == "" {
+		return "", fmt.Errorf("reindex: alias is required")
+	}
+
+	mapping := opts.Mapping
+	if mapping == "" && opts.MappingFile != "" {
+		data, err := os.ReadFile(opts.MappingFile)
+		if err != nil {
+			return "", fmt.Errorf("failed to read mapping file: %w", err)
+		}
+		mapping = string(data)
+	}
+
+	versions, err := IndexVersions(ctx, opts.Alias)
+	if err != nil {
+		return "", err
+	}
+	current, err := AliasTargets(ctx, opts.Alias)
+	if err != nil {
+		return "", err
+	}
+	if len(current) == 0 {
+		// An alias cannot be created while an index has its name
+		err := perform(ctx, esapi.IndicesExistsRequest{Index: []string{opts.Alias}}, nil)
+		if err == nil {
+			return "", fmt.Errorf("reindex: %s is an index, not an alias; reindex it into a versioned index and delete it first", opts.Alias)
+		}
+		if !IsNotFound(err) {
+			return "", fmt.Errorf("failed to check index %s: %w", opts.Alias, err)
+		}
+	}
+
+	next := 1
+	if len(versions) > 0 {
+		next = versions[len(versions)-1] + 1
+	}
+	index := versionedIndex(opts.Alias, next)
+
+	logger.Infof("Reindexing %s into %s", opts.Alias, index)
+	if err := CreateIndex(ctx, index, mapping); err != nil {
+		return "", err
+	}
+
+	if err := loadIndex(ctx, index, current, opts.Load); err != nil {
+		if delErr := DeleteIndex(context.WithoutCancel(ctx), index); delErr != nil {
+			logger.Errorf("Failed to delete incomplete index %s: %v", index, delErr)
+		}
+		return "", err
+	}
+
+	if err := perform(ctx, esapi.IndicesRefreshRequest{Index: []string{index}}, nil); err != nil {
+		return "", fmt.Errorf("failed to refresh %s: %w", index, err)
+	}
+
+	if err := SwapAlias(ctx, opts.Alias, index); err != nil {
+		return "", err
+	}
+	logger.Infof("Alias %s now points to %s", opts.Alias, index)
+
+	if opts.Load == nil && len(current) > 0 {
+		// Catch up with the writes that reached the old index while it was copied; the old
+		// versions are kept when this fails, so nothing is lost
+		if err := perform(ctx, esapi.IndicesRefreshRequest{Index: current}, nil); err != nil {
+			return index, fmt.Errorf("failed to refresh %s: %w", strings.Join(current, ", "), err)
+		}
+		if err := copyDocuments(ctx, current, index); err != nil {
+			return index, fmt.Errorf("failed to catch up with writes during the reindex: %w", err)
+		}
+	}
+
+	// Versions up to and including the new one, oldest first; delete all but the newest Keep+1
+	versions = append(versions, next)
+	for i := 0; i < len(versions)-opts.Keep-1; i++ {
+		old := versionedIndex(opts.Alias, versions[i])
+		if err := DeleteIndex(ctx, old); err != nil {
+			return index, err
+		}
+		logger.Infof("Deleted old index %s", old)
+	}
+
+	return index, nil
+}
+
+// loadIndex fills a new index with load or, without it, by copying from the current indices
+func loadIndex(ctx context.Context, index string, current []string, load func(context.Context, string) error) error {
+	if load != nil {
+		if err := load(ctx, index); err != nil {
+			return fmt.Errorf("failed to load %s: %w", index, err)
+		}
+		return nil
+	}
+	if len(current) == 0 {
+		return nil
+	}
+	return copyDocuments(ctx, current, index)
+}
+
+// copyDocuments runs _reindex as a background task and waits for it to complete. Documents
+// keep their version, and a document is only copied over one with a lower version, so running
+// it again copies just what changed in source without undoing newer writes to dest.
+func copyDocuments(ctx context.Context, source []string, dest string) error {
+	body := map[string]any{
+		"source":    map[string]any{"index": source},
+		"dest":      map[string]any{"index": dest, "version_type": "external"},
+		"conflicts": "proceed",
+	}
+	reader, err := requestBody(body)
+	if err != nil {
+		return err
+	}
+
+	wait := false
+	var started struct {
+		Task string `json:"task"`
+	}
+	if err := perform(ctx, esapi.ReindexRequest{Body: reader, WaitForCompletion: &wait}, &started); err != nil {
+		return fmt.Errorf("failed to start reindex into %s: %w", dest, err)
+	}
+
+	ticker := time.NewTicker(reindexPollInterval)
+	defer ticker.Stop()
+	for {
+		var task struct {
+			Completed bool `json:"completed"`
+			Task      struct {
+				Status struct {
+					Total   int64 `json:"total"`
+					Created int64 `json:"created"`
+				} `json:"status"`
+			} `json:"task"`
+			Response struct {
+				Failures []json.RawMessage `json:"failures"`
+			} `json:"response"`
+			Error *ErrorCause `json:"error"`
+		}
+		if err := perform(ctx, esapi.TasksGetRequest{TaskID: started.Task}, &task); err != nil {
+			return fmt.Errorf("failed to get reindex task %s: %w", started.Task, err)
+		}
+
+		if task.Completed {
+			if task.Error != nil {
+				return fmt.Errorf("reindex into %s failed: %s: %s", dest, task.Error.Type, task.Error.Reason)
+			}
+			if n := len(task.Response.Failures); n > 0 {
+				return fmt.Errorf("reindex into %s failed for %d documents, first: %s", dest, n, task.Response.Failures[0])
+			}
+			logger.Infof("Copied %d documents into %s", task.Task.Status.Created, dest)
+			return nil
+		}
+		logger.Infof("Reindex into %s: %d of %d documents", dest, task.Task.Status.Created, task.Task.Status.Total)
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			// The task keeps running in the cluster; cancel it so it does not fill an orphan index
+			cancel := esapi.TasksCancelRequest{TaskID: started.Task}
+			if err := perform(context.WithoutCancel(ctx), cancel, nil); err != nil {
+				logger.Errorf("Failed to cancel reindex task %s: %v", started.Task, err)
+			}
+			return ctx.Err()
+		}
+	}
+}
+
+// IndexVersions returns the version numbers of the <alias>_v<N> indices in ascending order
+func IndexVersions(ctx context.Context, alias string) ([]int, error) {
+	var indices map[string]json.RawMessage
+	req := esapi.IndicesGetRequest{Index: []string{alias + "_v*"}}
+	if err := perform(ctx, req, &indices); err != nil {
+		return nil, fmt.Errorf("failed to list versions of %s: %w", alias, err)
+	}
+
+	var versions []int
+	for name := range indices {
+		if v, ok := indexVersion(alias, name); ok {
+			versions = append(versions, v)
+		}
+	}
+	sort.Ints(versions)
+	return versions, nil
+}
+
+// AliasTargets returns the indices an alias points to; none when the alias does not exist
+func AliasTargets(ctx context.Context, alias string) ([]string, error) {
+	var aliases map[string]json.RawMessage
+	if err := perform(ctx, esapi.IndicesGetAliasRequest{Name: []string{alias}}, &aliases); err != nil {
+		if IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get alias %s: %w", alias, err)
+	}
+
+	targets := make([]string, 0, len(aliases))
+	for index := range aliases {
+		targets = append(targets, index)
+	}
+	sort.Strings(targets)
+	return targets, nil
+}
+
+// SwapAlias points alias at index alone, removing it from the indices it pointed to, in one
+// atomic request
+func SwapAlias(ctx context.Context, alias, index string) error {
+	current, err := AliasTargets(ctx, alias)
+	if err != nil {
+		return err
+	}
+
+	actions := make([]map[string]any, 0, len(current)+1)
+	for _, old := range current {
+		if old != index {
+			actions = append(actions, map[string]any{"remove": map[string]string{"index": old, "alias": alias}})
+		}
+	}
+	actions = append(actions, map[string]any{"add": map[string]any{"index": index, "alias": alias, "is_write_index": true}})
+
+	body, err := requestBody(map[string]any{"actions": actions})
+	if err != nil {
+		return err
+	}
+	if err := perform(ctx, esapi.IndicesUpdateAliasesRequest{Body: body}, nil); err != nil {
+		return fmt.Errorf("failed to point alias %s to %s: %w", alias, index, err)
+	}
+	return nil
+}
+
+func versionedIndex(alias string, version int) string {
+	return alias + "_v" + strconv.Itoa(version)
+}
+
+// indexVersion parses the version from an index named <alias>_v<N>
+func indexVersion(alias, index string) (int, bool) {
+	suffix, ok := strings.CutPrefix(index, alias+"_v")
+	if !ok {
+		return 0, false
+	}
+	v, err := strconv.Atoi(suffix)
+	return v, err == nil && v > 0
+}
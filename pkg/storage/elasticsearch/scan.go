@@ -0,0 +1,212 @@
+package elasticsearch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"iter"
+	"net/http"
+	"strconv"
+	"time"
+
+	"yourapp/pkg/logger"
+
+	"github.com/elastic/go-elasticsearch/v8/esapi"
+)
+
+// Defaults of a scan: hits per page, and how long the point in time or scroll is kept
+// between pages
+const (
+	scanPageSize  = 1000
+	scanKeepAlive = time.Minute
+)
+
+// pitKeepAlive is scanKeepAlive in the time unit format of Elasticsearch
+var pitKeepAlive = strconv.FormatInt(scanKeepAlive.Milliseconds(), 10) + "ms"
+
+// errStopScan ends a scan early without an error
+var errStopScan = errors.New("scan stopped")
+
+// Scan calls fn with every document matching q, reading pages from a point in time with
+// search_after so the result set stays consistent while it is read. Clusters without point in
+// time support (before 7.10) are read with a scroll instead. Scan stops at the first error
+// returned by fn or when ctx is cancelled, and always releases the point in time or scroll.
+//
+// q may be nil to read the whole index. Its size is the page size and from is ignored; without
+// a sort the documents are read in index order, which is the fastest.
+func (i *Index[T]) Scan(ctx context.Context, q *SearchRequest, fn func(Hit[T]) error) error {
+	if q == nil {
+		q = NewSearch()
+	}
+	body := q.Body()
+	delete(body, "from")
+	if _, ok := body["size"]; !ok {
+		body["size"] = scanPageSize
+	}
+	if _, ok := body["track_total_hits"]; !ok {
+		body["track_total_hits"] = false
+	}
+
+	id, err := i.openPointInTime(ctx)
+	switch {
+	case err == nil:
+		err = i.scanPointInTime(ctx, id, body, fn)
+	case pointInTimeUnsupported(err):
+		logger.Debugf("Point in time not supported for %s, scanning with scroll: %v", i.name, err)
+		err = i.scanScroll(ctx, body, fn)
+	}
+	if errors.Is(err, errStopScan) {
+		return nil
+	}
+	return err
+}
+
+// Stream runs Scan in a goroutine and sends the hits on the returned channel, which is closed
+// when the scan ends. The error channel then receives the result of the scan, nil on success.
+// Cancel ctx to stop reading early.
+func (i *Index[T]) Stream(ctx context.Context, q *SearchRequest) (<-chan Hit[T], <-chan error) {
+	hits := make(chan Hit[T])
+	errc := make(chan error, 1)
+
+	go func() {
+		err := i.Scan(ctx, q, func(h Hit[T]) error {
+			select {
+			case hits <- h:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+		close(hits)
+		errc <- err
+		close(errc)
+	}()
+
+	return hits, errc
+}
+
+// All returns an iterator over the documents matching q, read as by Scan. A failed scan yields
+// a final zero hit with the error; breaking out of the loop ends the scan.
+func (i *Index[T]) All(ctx context.Context, q *SearchRequest) iter.Seq2[Hit[T], error] {
+	return func(yield func(Hit[T], error) bool) {
+		err := i.Scan(ctx, q, func(h Hit[T]) error {
+			if !yield(h, nil) {
+				return errStopScan
+			}
+			return nil
+		})
+		if err != nil {
+			yield(Hit[T]{}, err)
+		}
+	}
+}
+
+func (i *Index[T]) openPointInTime(ctx context.Context) (string, error) {
+	var pit struct {
+		ID string `json:"id"`
+	}
+	req := esapi.OpenPointInTimeRequest{Index: []string{i.name}, KeepAlive: pitKeepAlive}
+	if err := perform(ctx, req, &pit); err != nil {
+		return "", fmt.Errorf("failed to open point in time on %s: %w", i.name, err)
+	}
+	return pit.ID, nil
+}
+
+func (i *Index[T]) scanPointInTime(ctx context.Context, id string, body map[string]any, fn func(Hit[T]) error) error {
+	defer func() {
+		reader, err := requestBody(map[string]string{"id": id})
+		if err == nil {
+			err = perform(context.WithoutCancel(ctx), esapi.ClosePointInTimeRequest{Body: reader}, nil)
+		}
+		if err != nil {
+			logger.Warnf("Failed to close point in time on %s: %v", i.name, err)
+		}
+	}()
+
+	if _, ok := body["sort"]; !ok {
+		body["sort"] = []any{"_shard_doc"}
+	}
+	for {
+		// A search on a point in time must not name the index
+		body["pit"] = map[string]any{"id": id, "keep_alive": pitKeepAlive}
+		reader, err := requestBody(body)
+		if err != nil {
+			return err
+		}
+		var res searchResponse[T]
+		if err := perform(ctx, esapi.SearchRequest{Body: reader}, &res); err != nil {
+			return fmt.Errorf("failed to scan %s: %w", i.name, err)
+		}
+		if res.PitID != "" {
+			id = res.PitID
+		}
+
+		hits := res.result().Hits
+		for _, h := range hits {
+			if err := fn(h); err != nil {
+				return err
+			}
+		}
+		if len(hits) == 0 || len(hits) < body["size"].(int) {
+			return nil
+		}
+		body["search_after"] = hits[len(hits)-1].Sort
+	}
+}
+
+func (i *Index[T]) scanScroll(ctx context.Context, body map[string]any, fn func(Hit[T]) error) error {
+	delete(body, "pit")
+	delete(body, "search_after")
+	if _, ok := body["sort"]; !ok {
+		body["sort"] = []any{"_doc"}
+	}
+	reader, err := requestBody(body)
+	if err != nil {
+		return err
+	}
+
+	var res searchResponse[T]
+	req := esapi.SearchRequest{Index: []string{i.name}, Body: reader, Scroll: scanKeepAlive}
+	if err := perform(ctx, req, &res); err != nil {
+		return fmt.Errorf("failed to scan %s: %w", i.name, err)
+	}
+
+	id := res.ScrollID
+	defer func() {
+		req := esapi.ClearScrollRequest{ScrollID: []string{id}}
+		if err := perform(context.WithoutCancel(ctx), req, nil); err != nil {
+			logger.Warnf("Failed to clear scroll on %s: %v", i.name, err)
+		}
+	}()
+
+	for len(res.Hits.Hits) > 0 {
+		for _, h := range res.result().Hits {
+			if err := fn(h); err != nil {
+				return err
+			}
+		}
+
+		res = searchResponse[T]{}
+		if err := perform(ctx, esapi.ScrollRequest{ScrollID: id, Scroll: scanKeepAlive}, &res); err != nil {
+			return fmt.Errorf("failed to scan %s: %w", i.name, err)
+		}
+		if res.ScrollID != "" {
+			id = res.ScrollID
+		}
+	}
+	return nil
+}
+
+// pointInTimeUnsupported reports whether opening a point in time failed because the cluster
+// predates the API, which older versions answer as an unknown endpoint or document type
+func pointInTimeUnsupported(err error) bool {
+	var e *Error
+	if !errors.As(err, &e) {
+		return false
+	}
+	switch e.StatusCode {
+	case http.StatusBadRequest, http.StatusNotFound, http.StatusMethodNotAllowed:
+		return e.Type != "index_not_found_exception"
+	}
+	return false
+}
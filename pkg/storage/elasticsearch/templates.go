@@ -0,0 +1,373 @@
+package elasticsearch
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"yourapp/pkg/logger"
+
+	"github.com/elastic/go-elasticsearch/v8/esapi"
+)
+
+// Kinds of cluster resources managed by ApplyTemplates, in the order they are applied:
+// index templates refer to component templates, which refer to ILM policies
+const (
+	KindILMPolicy         = "ilm_policy"
+	KindComponentTemplate = "component_template"
+	KindIndexTemplate     = "index_template"
+)
+
+// templateDirs maps each kind to its subdirectory in a templates directory
+var templateDirs = []struct {
+	kind string
+	dir  string
+}{
+	{KindILMPolicy, "ilm_policies"},
+	{KindComponentTemplate, "component_templates"},
+	{KindIndexTemplate, "index_templates"},
+}
+
+// Template is the desired state of an ILM policy, component template or index template.
+// Body is the request body of the matching put API.
+type Template struct {
+	Kind string
+	Name string
+	Body map[string]any
+}
+
+// TemplateDiff compares a template with the cluster
+type TemplateDiff struct {
+	Template Template
+	// Missing is true when the cluster has no resource with the name
+	Missing bool
+	// Changed lists the paths of the settings whose value on the cluster differs from the
+	// template, such as template.settings.index.number_of_shards
+	Changed []string
+}
+
+// UpToDate reports whether the cluster already matches the template
+func (d TemplateDiff) UpToDate() bool {
+	return !d.Missing && len(d.Changed) == 0
+}
+
+// LoadTemplates reads templates from a directory with the subdirectories ilm_policies,
+// component_templates and index_templates. Each <name>.json file holds the body of the put
+// API of its kind and is applied under the file name. Missing subdirectories are skipped.
+func LoadTemplates(dir string) ([]Template, error) {
+	var templates []Template
+	for _, d := range templateDirs {
+		files, err := filepath.Glob(filepath.Join(dir, d.dir, "*.json"))
+		if err != nil {
+			return nil, fmt.Errorf("failed to list %s templates: %w", d.kind, err)
+		}
+		sort.Strings(files)
+
+		for _, file := range files {
+			data, err := os.ReadFile(file)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read template file: %w", err)
+			}
+			t := Template{Kind: d.kind, Name: strings.TrimSuffix(filepath.Base(file), ".json")}
+			if err := json.Unmarshal(data, &t.Body); err != nil {
+				return nil, fmt.Errorf("failed to parse template file %s: %w", file, err)
+			}
+			templates = append(templates, t)
+		}
+	}
+	return templates, nil
+}
+
+// DiffTemplates compares templates with the cluster without changing it. Settings the cluster
+// adds on its own, such as defaults, are ignored: only values present in a template count.
+func DiffTemplates(ctx context.Context, templates []Template) ([]TemplateDiff, error) {
+	diffs := make([]TemplateDiff, 0, len(templates))
+	for _, t := range templates {
+		current, err := getTemplate(ctx, t.Kind, t.Name)
+		if err != nil {
+			return nil, err
+		}
+		diff := TemplateDiff{Template: t, Missing: current == nil}
+		if current != nil {
+			diff.Changed = changedPaths(t.Body, current)
+		}
+		diffs = append(diffs, diff)
+	}
+	return diffs, nil
+}
+
+// ApplyTemplates puts every template that is missing from the cluster or differs from it,
+// ILM policies first and index templates last, and leaves the others untouched
+func ApplyTemplates(ctx context.Context, templates []Template) error {
+	sorted := make([]Template, len(templates))
+	copy(sorted, templates)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return kindOrder(sorted[i].Kind) < kindOrder(sorted[j].Kind)
+	})
+
+	diffs, err := DiffTemplates(ctx, sorted)
+	if err != nil {
+		return err
+	}
+	for _, d := range diffs {
+		t := d.Template
+		switch {
+		case d.UpToDate():
+			logger.Debugf("Elasticsearch %s %s is up to date", t.Kind, t.Name)
+			continue
+		case d.Missing:
+			logger.Infof("Creating Elasticsearch %s %s", t.Kind, t.Name)
+		default:
+			logger.Infof("Updating Elasticsearch %s %s: %s changed", t.Kind, t.Name, strings.Join(d.Changed, ", "))
+		}
+		if err := putTemplate(ctx, t.Kind, t.Name, t.Body); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ApplyTemplatesDir loads the templates of a directory and applies them; see LoadTemplates
+func ApplyTemplatesDir(ctx context.Context, dir string) error {
+	templates, err := LoadTemplates(dir)
+	if err != nil {
+		return err
+	}
+	return ApplyTemplates(ctx, templates)
+}
+
+func kindOrder(kind string) int {
+	for i, d := range templateDirs {
+		if d.kind == kind {
+			return i
+		}
+	}
+	return len(templateDirs)
+}
+
+// PutILMPolicy creates or replaces an ILM policy; body is {"policy": {"phases": ...}}
+func PutILMPolicy(ctx context.Context, name string, body any) error {
+	return putTemplate(ctx, KindILMPolicy, name, body)
+}
+
+// GetILMPolicy returns the body of an ILM policy, or ErrNotFound
+func GetILMPolicy(ctx context.Context, name string) (map[string]any, error) {
+	return getExisting(ctx, KindILMPolicy, name)
+}
+
+// DeleteILMPolicy deletes an ILM policy
+func DeleteILMPolicy(ctx context.Context, name string) error {
+	return deleteTemplate(ctx, KindILMPolicy, name)
+}
+
+// PutComponentTemplate creates or replaces a component template
+func PutComponentTemplate(ctx context.Context, name string, body any) error {
+	return putTemplate(ctx, KindComponentTemplate, name, body)
+}
+
+// GetComponentTemplate returns the body of a component template, or ErrNotFound
+func GetComponentTemplate(ctx context.Context, name string) (map[string]any, error) {
+	return getExisting(ctx, KindComponentTemplate, name)
+}
+
+// DeleteComponentTemplate deletes a component template
+func DeleteComponentTemplate(ctx context.Context, name string) error {
+	return deleteTemplate(ctx, KindComponentTemplate, name)
+}
+
+// PutIndexTemplate creates or replaces a composable index template
+func PutIndexTemplate(ctx context.Context, name string, body any) error {
+	return putTemplate(ctx, KindIndexTemplate, name, body)
+}
+
+// GetIndexTemplate returns the body of a composable index template, or ErrNotFound
+func GetIndexTemplate(ctx context.Context, name string) (map[string]any, error) {
+	return getExisting(ctx, KindIndexTemplate, name)
+}
+
+// DeleteIndexTemplate deletes a composable index template
+func DeleteIndexTemplate(ctx context.Context, name string) error {
+	return deleteTemplate(ctx, KindIndexTemplate, name)
+}
+
+func putTemplate(ctx context.Context, kind, name string, body any) error {
+	reader, err := requestBody(body)
+	if err != nil {
+		return err
+	}
+
+	var req esapi.Request
+	switch kind {
+	case KindILMPolicy:
+		req = esapi.ILMPutLifecycleRequest{Policy: name, Body: reader}
+	case KindComponentTemplate:
+		req = esapi.ClusterPutComponentTemplateRequest{Name: name, Body: reader}
+	case KindIndexTemplate:
+		req = esapi.IndicesPutIndexTemplateRequest{Name: name, Body: reader}
+	default:
+		return fmt.Errorf("unknown template kind %q", kind)
+	}
+	if err := perform(ctx, req, nil); err != nil {
+		return fmt.Errorf("failed to put %s %s: %w", kind, name, err)
+	}
+	return nil
+}
+
+func deleteTemplate(ctx context.Context, kind, name string) error {
+	var req esapi.Request
+	switch kind {
+	case KindILMPolicy:
+		req = esapi.ILMDeleteLifecycleRequest{Policy: name}
+	case KindComponentTemplate:
+		req = esapi.ClusterDeleteComponentTemplateRequest{Name: name}
+	case KindIndexTemplate:
+		req = esapi.IndicesDeleteIndexTemplateRequest{Name: name}
+	default:
+		return fmt.Errorf("unknown template kind %q", kind)
+	}
+	if err := perform(ctx, req, nil); err != nil {
+		return fmt.Errorf("failed to delete %s %s: %w", kind, name, err)
+	}
+	return nil
+}
+
+func getExisting(ctx context.Context, kind, name string) (map[string]any, error) {
+	body, err := getTemplate(ctx, kind, name)
+	if err == nil && body == nil {
+		err = fmt.Errorf("%s %s: %w", kind, name, ErrNotFound)
+	}
+	return body, err
+}
+
+// getTemplate returns the current body of a resource in the shape of its put API, or nil when
+// it does not exist
+func getTemplate(ctx context.Context, kind, name string) (map[string]any, error) {
+	var req esapi.Request
+	switch kind {
+	case KindILMPolicy:
+		req = esapi.ILMGetLifecycleRequest{Policy: name}
+	case KindComponentTemplate:
+		req = esapi.ClusterGetComponentTemplateRequest{Name: []string{name}}
+	case KindIndexTemplate:
+		req = esapi.IndicesGetIndexTemplateRequest{Name: name}
+	default:
+		return nil, fmt.Errorf("unknown template kind %q", kind)
+	}
+
+	var res map[string]json.RawMessage
+	if err := perform(ctx, req, &res); err != nil {
+		if IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get %s %s: %w", kind, name, err)
+	}
+
+	// ILM policies are returned as {name: {"policy": ...}}, templates as a list of
+	// {"name", "<kind>": body} under index_templates or component_templates
+	var body map[string]any
+	switch kind {
+	case KindILMPolicy:
+		raw, ok := res[name]
+		if !ok {
+			return nil, nil
+		}
+		var policy struct {
+			Policy map[string]any `json:"policy"`
+		}
+		if err := json.Unmarshal(raw, &policy); err != nil {
+			return nil, fmt.Errorf("failed to decode %s %s: %w", kind, name, err)
+		}
+		body = map[string]any{"policy": policy.Policy}
+	default:
+		var list []map[string]json.RawMessage
+		if err := json.Unmarshal(res[kind+"s"], &list); err != nil {
+			return nil, fmt.Errorf("failed to decode %s %s: %w", kind, name, err)
+		}
+		for _, item := range list {
+			var n string
+			if json.Unmarshal(item["name"], &n) == nil && n == name {
+				if err := json.Unmarshal(item[kind], &body); err != nil {
+					return nil, fmt.Errorf("failed to decode %s %s: %w", kind, name, err)
+				}
+			}
+		}
+	}
+	return body, nil
+}
+
+// changedPaths returns the paths of the values in desired that differ from current, in sorted
+// order. Settings are compared by their full index.* name and scalars by their text, since
+// Elasticsearch returns settings as strings.
+func changedPaths(desired, current map[string]any) []string {
+	want := make(map[string]any)
+	flatten("", desired, want)
+	have := make(map[string]any)
+	flatten("", current, have)
+
+	var changed []string
+	for path, v := range want {
+		if !sameValue(v, have[path]) {
+			changed = append(changed, path)
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
+
+// flatten collects the leaves of v under dotted paths. Objects are descended into; arrays are
+// leaves.
+func flatten(prefix string, v any, out map[string]any) {
+	m, ok := v.(map[string]any)
+	if !ok {
+		out[prefix] = v
+		return
+	}
+	if len(m) == 0 {
+		out[prefix] = m
+		return
+	}
+	for key, child := range m {
+		path := key
+		if prefix != "" {
+			path = prefix + "." + key
+		}
+		flatten(normalizeSettingPath(path), child, out)
+	}
+}
+
+// normalizeSettingPath spells settings with their index. prefix, as the cluster returns them
+func normalizeSettingPath(path string) string {
+	const settings = "settings."
+	i := strings.Index(path, settings)
+	if i < 0 || (i > 0 && path[i-1] != '.') {
+		return path
+	}
+	rest := path[i+len(settings):]
+	if rest == "index" || strings.HasPrefix(rest, "index.") {
+		return path
+	}
+	return path[:i] + settings + "index." + rest
+}
+
+func sameValue(a, b any) bool {
+	if isEmpty(a) || isEmpty(b) {
+		return isEmpty(a) && isEmpty(b)
+	}
+	switch a.(type) {
+	case map[string]any, []any:
+		aj, _ := json.Marshal(a)
+		bj, _ := json.Marshal(b)
+		return string(aj) == string(bj)
+	}
+	return fmt.Sprint(a) == fmt.Sprint(b)
+}
+
+// isEmpty reports whether v is absent or an empty object, which the cluster may omit
+func isEmpty(v any) bool {
+	m, ok := v.(map[string]any)
+	return v == nil || ok && len(m) == 0
+}
@@ -0,0 +1,440 @@
+// Package essync keeps an Elasticsearch index in sync with a SQL table. A sync first loads
+// every row with bulk requests, then applies changes found by polling an updated_at column or
+// announced by change events on a Kafka topic. Its progress is checkpointed in the database,
+// so a restarted sync resumes where it stopped.
+package essync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync/atomic"
+	"time"
+
+	"yourapp/pkg/logger"
+	"yourapp/pkg/messaging/kafka"
+	"yourapp/pkg/messaging/outbox"
+	"yourapp/pkg/storage/elasticsearch"
+
+	"github.com/elastic/go-elasticsearch/v8/esutil"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+const (
+	defaultBatchSize    = 500
+	defaultPollInterval = 5 * time.Second
+	// watermarkSkew is subtracted from the start of the initial load, so rows changed while
+	// it runs are indexed again by the poller despite clock differences with the database. The
+	// poller also reads again the rows up to watermarkSkew before its watermark, to catch rows
+	// of transactions that committed after later rows were indexed.
+	watermarkSkew = time.Minute
+)
+
+// ChangeEventType is the event type of change events
+const ChangeEventType = "essync.change"
+
+// Options configures a sync of the table of model M
+type Options[M any] struct {
+	// Name identifies the sync in the checkpoint table; empty means Index
+	Name  string
+	DB    *gorm.DB
+	Index string
+	// Document converts a row into the indexed document; nil indexes the row as JSON
+	Document func(*M) (any, error)
+	// UpdatedAtColumn is the column polled for changes; empty means updated_at. Writers must set
+	// it on every change, and rows deleted without a soft delete are not seen by the poller.
+	UpdatedAtColumn string
+	// Topic selects change events consumed from Kafka instead of polling; see Change. Events
+	// published during the initial load are applied after it, so Run refuses to load unless
+	// the consumer group has committed offsets for the topic or starts from the earliest offset.
+	Topic string
+	// Codec decodes change events; nil picks it from the content-type header
+	Codec        kafka.Codec
+	BatchSize    int
+	PollInterval time.Duration
+}
+
+// Change is the data of a change event: the primary key of a row that was written or deleted.
+// The row is read again from the database, so ordering of events for one row only matters
+// per key; use the key as the Kafka message key. See EnqueueChange.
+type Change struct {
+	Key string `json:"key"`
+}
+
+// Checkpoint is the stored progress of a sync
+type Checkpoint struct {
+	Name string `gorm:"primaryKey;size:191"`
+	// Loaded is set once the initial load has completed; until then LoadedKey is the primary
+	// key of the last row loaded
+	Loaded    bool   `gorm:"not null;default:false"`
+	LoadedKey string `gorm:"size:191"`
+	// Watermark and WatermarkKey are the updated_at and primary key of the last row indexed by
+	// the poller. Polling resumes watermarkSkew before Watermark.
+	Watermark    time.Time
+	WatermarkKey string `gorm:"size:191"`
+	UpdatedAt    time.Time
+}
+
+// TableName returns the checkpoint table name
+func (Checkpoint) TableName() string {
+	return "search_sync_checkpoints"
+}
+
+// Syncer syncs the table of model M into an index. Documents are stored under the primary
+// key of their row.
+type Syncer[M any] struct {
+	opts      Options[M]
+	docs      *elasticsearch.Index[any]
+	pk        *schema.Field
+	updatedAt *schema.Field
+	deletedAt *schema.Field
+	// recent holds the updated_at of the rows the poller indexed within watermarkSkew of its
+	// watermark, so reading them again does not index them again
+	recent map[string]time.Time
+}
+
+// New creates a sync, creating the checkpoint table if needed
+func New[M any](opts Options[M]) (*Syncer[M], error) {
+	if opts.DB == nil {
+		return nil, fmt.Errorf("sync database not initialized")
+	}
+	if opts.Index == "" {
+		return nil, fmt.Errorf("sync index is required")
+	}
+	if opts.Name == "" {
+		opts.Name = opts.Index
+	}
+	if opts.UpdatedAtColumn == "" {
+		opts.UpdatedAtColumn = "updated_at"
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = defaultBatchSize
+	}
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = defaultPollInterval
+	}
+
+	stmt := &gorm.Statement{DB: opts.DB}
+	if err := stmt.Parse(new(M)); err != nil {
+		return nil, fmt.Errorf("failed to parse sync model: %w", err)
+	}
+	s := &Syncer[M]{opts: opts, docs: elasticsearch.NewIndex[any](opts.Index), recent: map[string]time.Time{}}
+	if s.pk = stmt.Schema.PrioritizedPrimaryField; s.pk == nil {
+		return nil, fmt.Errorf("sync model %s has no single primary key", stmt.Schema.Name)
+	}
+	if s.updatedAt = stmt.Schema.LookUpField(opts.UpdatedAtColumn); s.updatedAt == nil && opts.Topic == "" {
+		return nil, fmt.Errorf("sync model %s has no %s column to poll", stmt.Schema.Name, opts.UpdatedAtColumn)
+	}
+	if f := stmt.Schema.LookUpField("deleted_at"); f != nil && f.FieldType == reflect.TypeOf(gorm.DeletedAt{}) {
+		s.deletedAt = f
+	}
+
+	if err := opts.DB.AutoMigrate(&Checkpoint{}); err != nil {
+		return nil, fmt.Errorf("failed to migrate sync checkpoint table: %w", err)
+	}
+	return s, nil
+}
+
+// Run completes the initial load if needed, then applies changes until ctx is cancelled
+func (s *Syncer[M]) Run(ctx context.Context) error {
+	cp, err := s.checkpoint(ctx)
+	if err != nil {
+		return err
+	}
+
+	if !cp.Loaded {
+		if s.opts.Topic != "" {
+			// Events published while loading would be skipped by a group starting at the latest offset
+			ok, err := kafka.ReadsBacklog(ctx, s.opts.Topic)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				return fmt.Errorf("sync %s: the consumer group would skip changes made during the initial load; set kafka.consumer.auto_offset_reset to earliest", s.opts.Name)
+			}
+		}
+		if err := s.load(ctx, cp); err != nil {
+			return err
+		}
+	}
+
+	if s.opts.Topic != "" {
+		logger.Infof("Sync %s applying change events from %s", s.opts.Name, s.opts.Topic)
+		err = kafka.Consume(ctx, s.opts.Topic, s.opts.Codec, func(ctx context.Context, ev *kafka.Event[Change]) error {
+			key := ev.Data.Key
+			if key == "" {
+				key = ev.Key
+			}
+			return s.Sync(ctx, key)
+		})
+	} else {
+		logger.Infof("Sync %s polling %s for changes", s.opts.Name, s.opts.UpdatedAtColumn)
+		err = s.poll(ctx, cp)
+	}
+	if errors.Is(err, context.Canceled) {
+		return nil
+	}
+	return err
+}
+
+// Sync reads the row with the given primary key and indexes it, or deletes its document when
+// the row is gone
+func (s *Syncer[M]) Sync(ctx context.Context, key string) error {
+	var row M
+	err := s.opts.DB.WithContext(ctx).Where(s.pk.DBName+" = ?", key).Take(&row).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		if err := s.docs.Delete(ctx, key); err != nil && !elasticsearch.IsNotFound(err) {
+			return err
+		}
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read row %s for sync %s: %w", key, s.opts.Name, err)
+	}
+
+	doc, err := s.document(&row)
+	if err != nil {
+		return err
+	}
+	_, err = s.docs.Index(ctx, key, doc)
+	return err
+}
+
+// load indexes every row in primary key order, checkpointing after each batch
+func (s *Syncer[M]) load(ctx context.Context, cp *Checkpoint) error {
+	if cp.LoadedKey == "" {
+		cp.Watermark = time.Now().Add(-watermarkSkew)
+		if err := s.save(ctx, cp); err != nil {
+			return err
+		}
+		logger.Infof("Sync %s starting initial load into %s", s.opts.Name, s.opts.Index)
+	} else {
+		logger.Infof("Sync %s resuming initial load after key %s", s.opts.Name, cp.LoadedKey)
+	}
+
+	var total int
+	for {
+		var rows []M
+		q := s.opts.DB.WithContext(ctx).Order(s.pk.DBName).Limit(s.opts.BatchSize)
+		if cp.LoadedKey != "" {
+			q = q.Where(s.pk.DBName+" > ?", cp.LoadedKey)
+		}
+		if err := q.Find(&rows).Error; err != nil {
+			return fmt.Errorf("failed to read rows for sync %s: %w", s.opts.Name, err)
+		}
+		if len(rows) == 0 {
+			break
+		}
+
+		if err := s.indexRows(ctx, rows); err != nil {
+			return err
+		}
+		total += len(rows)
+		cp.LoadedKey = s.key(ctx, &rows[len(rows)-1])
+		if err := s.save(ctx, cp); err != nil {
+			return err
+		}
+	}
+
+	cp.Loaded = true
+	if err := s.save(ctx, cp); err != nil {
+		return err
+	}
+	logger.Infof("Sync %s loaded %d rows into %s", s.opts.Name, total, s.opts.Index)
+	return nil
+}
+
+// poll indexes the rows changed since the watermark every PollInterval until ctx is cancelled.
+// Each pass starts watermarkSkew before the watermark: a row whose transaction commits after
+// rows updated later were indexed is still found, as long as it commits within watermarkSkew.
+func (s *Syncer[M]) poll(ctx context.Context, cp *Checkpoint) error {
+	ticker := time.NewTicker(s.opts.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		// Drain the backlog without waiting between full batches
+		cursor := position{at: cp.Watermark.Add(-watermarkSkew)}
+		for {
+			n, err := s.pollBatch(ctx, cp, &cursor)
+			if err != nil {
+				if ctx.Err() == nil {
+					logger.Errorf("Sync %s failed: %v", s.opts.Name, err)
+				}
+				break
+			}
+			if n < s.opts.BatchSize {
+				break
+			}
+		}
+		s.forget(cp.Watermark.Add(-watermarkSkew))
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// position is an (updated_at, primary key) point in the order rows are polled in
+type position struct {
+	at  time.Time
+	key string
+}
+
+// pollBatch reads the next rows in (updated_at, primary key) order after cursor, indexes those
+// not indexed yet with the same updated_at and advances cursor and the watermark. It returns
+// the number of rows read. Soft deleted rows are included so their documents are deleted.
+func (s *Syncer[M]) pollBatch(ctx context.Context, cp *Checkpoint, cursor *position) (int, error) {
+	updatedAt, pk := s.updatedAt.DBName, s.pk.DBName
+	q := s.opts.DB.WithContext(ctx).Unscoped().
+		Order(updatedAt + ", " + pk).
+		Limit(s.opts.BatchSize)
+	if cursor.key == "" {
+		q = q.Where(updatedAt+" >= ?", cursor.at)
+	} else {
+		q = q.Where(updatedAt+" > ? OR ("+updatedAt+" = ? AND "+pk+" > ?)", cursor.at, cursor.at, cursor.key)
+	}
+
+	var rows []M
+	if err := q.Find(&rows).Error; err != nil {
+		return 0, fmt.Errorf("failed to read changed rows: %w", err)
+	}
+	if len(rows) == 0 {
+		return 0, nil
+	}
+
+	changed := make([]M, 0, len(rows))
+	times := make([]time.Time, len(rows))
+	for i := range rows {
+		value, _ := s.updatedAt.ValueOf(ctx, reflect.ValueOf(&rows[i]).Elem())
+		at, ok := value.(time.Time)
+		if !ok {
+			return 0, fmt.Errorf("column %s of sync %s is not a time", updatedAt, s.opts.Name)
+		}
+		times[i] = at
+		if seen, ok := s.recent[s.key(ctx, &rows[i])]; !ok || !seen.Equal(at) {
+			changed = append(changed, rows[i])
+		}
+	}
+
+	if len(changed) > 0 {
+		if err := s.indexRows(ctx, changed); err != nil {
+			return 0, err
+		}
+		logger.Debugf("Sync %s indexed %d changed rows", s.opts.Name, len(changed))
+	}
+	for i := range rows {
+		s.recent[s.key(ctx, &rows[i])] = times[i]
+	}
+
+	last := len(rows) - 1
+	*cursor = position{at: times[last], key: s.key(ctx, &rows[last])}
+	if times[last].Before(cp.Watermark) {
+		return len(rows), nil
+	}
+	cp.Watermark, cp.WatermarkKey = cursor.at, cursor.key
+	return len(rows), s.save(ctx, cp)
+}
+
+// forget drops the rows updated before since from the rows known to be indexed
+func (s *Syncer[M]) forget(since time.Time) {
+	for key, at := range s.recent {
+		if at.Before(since) {
+			delete(s.recent, key)
+		}
+	}
+}
+
+// indexRows writes rows with a bulk indexer and waits until every item is settled. Soft
+// deleted rows delete their document.
+func (s *Syncer[M]) indexRows(ctx context.Context, rows []M) error {
+	b, err := elasticsearch.NewBulkIndexer(s.opts.Index)
+	if err != nil {
+		return err
+	}
+
+	var failed atomic.Int64
+	onFailure := func(ctx context.Context, item elasticsearch.BulkItem, res esutil.BulkIndexerResponseItem, err error) {
+		if item.Action == elasticsearch.ActionDelete && res.Status == 404 {
+			return
+		}
+		failed.Add(1)
+		logger.Errorf("Sync %s failed to %s document %s: %v", s.opts.Name, item.Action, item.ID, err)
+	}
+
+	for i := range rows {
+		item := elasticsearch.BulkItem{Action: elasticsearch.ActionDelete, ID: s.key(ctx, &rows[i]), OnFailure: onFailure}
+		if !s.deleted(ctx, &rows[i]) {
+			item.Action = elasticsearch.ActionIndex
+			item.Body, err = s.document(&rows[i])
+		}
+		if err == nil {
+			err = b.Add(ctx, item)
+		}
+		if err != nil {
+			_ = b.Close(ctx)
+			return err
+		}
+	}
+
+	if err := b.Close(ctx); err != nil {
+		return err
+	}
+	if n := failed.Load(); n > 0 {
+		return fmt.Errorf("sync %s failed for %d of %d rows", s.opts.Name, n, len(rows))
+	}
+	return nil
+}
+
+func (s *Syncer[M]) document(row *M) (any, error) {
+	if s.opts.Document == nil {
+		return row, nil
+	}
+	doc, err := s.opts.Document(row)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert row %v for sync %s: %w", row, s.opts.Name, err)
+	}
+	return doc, nil
+}
+
+// key returns the primary key of row as text
+func (s *Syncer[M]) key(ctx context.Context, row *M) string {
+	value, _ := s.pk.ValueOf(ctx, reflect.ValueOf(row).Elem())
+	return fmt.Sprint(value)
+}
+
+func (s *Syncer[M]) deleted(ctx context.Context, row *M) bool {
+	if s.deletedAt == nil {
+		return false
+	}
+	_, zero := s.deletedAt.ValueOf(ctx, reflect.ValueOf(row).Elem())
+	return !zero
+}
+
+// checkpoint returns the stored progress, or an empty one for a new sync
+func (s *Syncer[M]) checkpoint(ctx context.Context) (*Checkpoint, error) {
+	cp := &Checkpoint{Name: s.opts.Name}
+	err := s.opts.DB.WithContext(ctx).Where("name = ?", s.opts.Name).Take(cp).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("failed to read checkpoint of sync %s: %w", s.opts.Name, err)
+	}
+	return cp, nil
+}
+
+func (s *Syncer[M]) save(ctx context.Context, cp *Checkpoint) error {
+	if err := s.opts.DB.WithContext(ctx).Save(cp).Error; err != nil {
+		return fmt.Errorf("failed to save checkpoint of sync %s: %w", s.opts.Name, err)
+	}
+	return nil
+}
+
+// EnqueueChange stores a change event for the row with the given key in the outbox using tx,
+// the transaction that writes the row, so syncs consuming topic see every committed change
+func EnqueueChange(ctx context.Context, tx *gorm.DB, topic, key string) error {
+	ev := &kafka.Event[Change]{Key: key, Data: Change{Key: key}}
+	ev.Type = ChangeEventType
+	return outbox.EnqueueEvent(ctx, tx, topic, ev, nil)
+}